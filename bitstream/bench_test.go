@@ -0,0 +1,215 @@
+package bitstream
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// legacyStream the original byte then bit at a time writer (and destructive
+// reader), kept around to check the word at a time versions against
+type legacyStream struct {
+	stream []byte
+	count  uint8
+}
+
+func (b *legacyStream) writeBit(bit Bit) {
+	if b.count == 0 {
+		b.stream = append(b.stream, 0)
+		b.count = 8
+	}
+	i := len(b.stream) - 1
+	if bit {
+		b.stream[i] |= 1 << (b.count - 1)
+	}
+	b.count--
+}
+
+func (b *legacyStream) writeByte(byt byte) {
+	if b.count == 0 {
+		b.stream = append(b.stream, 0)
+		b.count = 8
+	}
+	i := len(b.stream) - 1
+	b.stream[i] |= byt >> (8 - b.count)
+	b.stream = append(b.stream, 0)
+	i++
+	b.stream[i] = byt << b.count
+}
+
+func (b *legacyStream) writeBits(u uint64, nbits int) {
+	u <<= (64 - uint(nbits))
+	for nbits >= 8 {
+		b.writeByte(byte(u >> 56))
+		u <<= 8
+		nbits -= 8
+	}
+	for nbits > 0 {
+		b.writeBit((u >> 63) == 1)
+		u <<= 1
+		nbits--
+	}
+}
+
+func (b *legacyStream) readBit() Bit {
+	if b.count == 0 {
+		b.stream = b.stream[1:]
+		b.count = 8
+	}
+	b.count--
+	d := b.stream[0] & 0x80
+	b.stream[0] <<= 1
+	return d != 0
+}
+
+func (b *legacyStream) readByte() byte {
+	if b.count == 0 {
+		b.stream = b.stream[1:]
+		b.count = 8
+	}
+	if b.count == 8 {
+		b.count = 0
+		return b.stream[0]
+	}
+	byt := b.stream[0]
+	b.stream = b.stream[1:]
+	byt |= b.stream[0] >> b.count
+	b.stream[0] <<= (8 - b.count)
+	return byt
+}
+
+func (b *legacyStream) readBits(nbits int) uint64 {
+	var u uint64
+	for nbits >= 8 {
+		u = (u << 8) | uint64(b.readByte())
+		nbits -= 8
+	}
+	for nbits > 0 {
+		u <<= 1
+		if b.readBit() {
+			u |= 1
+		}
+		nbits--
+	}
+	return u
+}
+
+type benchOp struct {
+	u     uint64
+	nbits int
+}
+
+func benchOps(n int) []benchOp {
+	rnd := rand.New(rand.NewSource(1))
+	ops := make([]benchOp, n)
+	for i := range ops {
+		ops[i] = benchOp{u: rnd.Uint64(), nbits: rnd.Intn(65)}
+	}
+	return ops
+}
+
+func TestWriteBitsCompat(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for run := 0; run < 200; run++ {
+		w := NewWriter(rnd.Intn(16))
+		l := &legacyStream{}
+		for i := 0; i < 100; i++ {
+			u := rnd.Uint64()
+			switch rnd.Intn(4) {
+			case 0:
+				w.WriteBit(u&1 == 1)
+				l.writeBit(u&1 == 1)
+			case 1:
+				w.WriteByte(byte(u))
+				l.writeByte(byte(u))
+			default:
+				n := rnd.Intn(65)
+				w.WriteBits(u, n)
+				l.writeBits(u, n)
+			}
+			if w.count != l.count || !bytes.Equal(w.Bytes(), l.stream) {
+				t.Fatalf("run %d op %d: stream differs\n%x (%d)\n%x (%d)", run, i, w.Bytes(), w.count, l.stream, l.count)
+			}
+		}
+	}
+}
+
+func TestReadBitsCompat(t *testing.T) {
+	ops := benchOps(1000)
+	w := NewWriter(0)
+	for _, op := range ops {
+		w.WriteBits(op.u, op.nbits)
+	}
+	l := &legacyStream{stream: append([]byte{}, w.Bytes()...), count: 8}
+	r := w.Reader()
+	for i, op := range ops {
+		u, err := r.ReadBits(op.nbits)
+		if err != nil {
+			t.Fatalf("read %d failed: %v", i, err)
+		}
+		if lu := l.readBits(op.nbits); u != lu {
+			t.Fatalf("read %d: %x != legacy %x", i, u, lu)
+		}
+	}
+}
+
+func BenchmarkWriteBits(b *testing.B) {
+	ops := benchOps(1024)
+	w := NewWriter(8 * 1024)
+	b.SetBytes(int64(len(ops)) * 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.stream, w.count = w.stream[:0], 0
+		for _, op := range ops {
+			w.WriteBits(op.u, op.nbits)
+		}
+	}
+}
+
+func BenchmarkLegacyWriteBits(b *testing.B) {
+	ops := benchOps(1024)
+	l := &legacyStream{stream: make([]byte, 0, 8*1024)}
+	b.SetBytes(int64(len(ops)) * 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.stream, l.count = l.stream[:0], 0
+		for _, op := range ops {
+			l.writeBits(op.u, op.nbits)
+		}
+	}
+}
+
+func BenchmarkReadBits(b *testing.B) {
+	ops := benchOps(1024)
+	w := NewWriter(8 * 1024)
+	for _, op := range ops {
+		w.WriteBits(op.u, op.nbits)
+	}
+	b.SetBytes(int64(len(ops)) * 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := w.Reader()
+		for _, op := range ops {
+			r.ReadBits(op.nbits)
+		}
+	}
+}
+
+func BenchmarkLegacyReadBits(b *testing.B) {
+	ops := benchOps(1024)
+	w := NewWriter(8 * 1024)
+	for _, op := range ops {
+		w.WriteBits(op.u, op.nbits)
+	}
+	buf := make([]byte, len(w.Bytes()))
+	b.SetBytes(int64(len(ops)) * 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// the legacy reader eats the stream, so it needs a fresh copy each time
+		copy(buf, w.Bytes())
+		l := &legacyStream{stream: buf, count: 8}
+		for _, op := range ops {
+			l.readBits(op.nbits)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package bitstream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+/*
+MarshalBinary format
+
+	magic    "BTS" (3 bytes)
+	version  1 byte
+	order    1 byte (BitOrder)
+	count    1 byte (free bits in the last byte)
+	bitLen   uvarint (exact number of valid bits)
+	written  uvarint (bitsWritten)
+	read     uvarint (bitsRead)
+	stream   (bitLen + count) / 8 bytes
+	crc      4 bytes big endian CRC32C (Castagnoli) of everything before it
+
+The old format was just the count byte followed by the stream, and as count is
+never more then 8 it can't be confused with the magic
+*/
+
+var binaryMagic = []byte("BTS")
+
+const binaryVersion = 1
+
+// ErrBadFormat the binary data is not a BitStream
+var ErrBadFormat = errors.New("bitstream: invalid binary format")
+
+// ErrTruncated the binary data is too short
+var ErrTruncated = errors.New("bitstream: truncated binary data")
+
+// ErrChecksum the binary data failed its checksum
+var ErrChecksum = errors.New("bitstream: binary checksum mismatch")
+
+// ErrVersion the binary data is from an unknown version
+var ErrVersion = errors.New("bitstream: unsupported binary version")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface
+func (b *BitStream) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 0, len(b.stream)+len(binaryMagic)+3+3*binary.MaxVarintLen64+crc32.Size)
+	out = append(out, binaryMagic...)
+	out = append(out, binaryVersion, byte(b.order), b.count)
+	out = binary.AppendUvarint(out, uint64(b.validBits()))
+	out = binary.AppendUvarint(out, uint64(b.bitsWritten))
+	out = binary.AppendUvarint(out, uint64(b.bitsRead))
+	out = append(out, b.stream...)
+	return binary.BigEndian.AppendUint32(out, crc32.Checksum(out, crcTable)), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface
+// both the current and the old (count byte + stream) formats are read
+func (b *BitStream) UnmarshalBinary(bIn []byte) error {
+	if len(bIn) == 0 {
+		return ErrTruncated
+	}
+	if bIn[0] <= 8 {
+		return b.unmarshalLegacy(bIn)
+	}
+
+	hdr := len(binaryMagic) + 3
+	if len(bIn) < hdr+crc32.Size {
+		if !bytes.HasPrefix(binaryMagic, bIn[:min(len(bIn), len(binaryMagic))]) {
+			return ErrBadFormat
+		}
+		return ErrTruncated
+	}
+	if !bytes.Equal(bIn[:len(binaryMagic)], binaryMagic) {
+		return ErrBadFormat
+	}
+	if bIn[len(binaryMagic)] != binaryVersion {
+		return ErrVersion
+	}
+
+	body, sum := bIn[:len(bIn)-crc32.Size], bIn[len(bIn)-crc32.Size:]
+	if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(sum) {
+		return ErrChecksum
+	}
+
+	order := BitOrder(bIn[len(binaryMagic)+1])
+	count := bIn[len(binaryMagic)+2]
+	rest := body[hdr:]
+
+	var vals [3]uint64
+	for i := range vals {
+		v, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return ErrBadFormat
+		}
+		vals[i] = v
+		rest = rest[n:]
+	}
+	bitLen, written, read := vals[0], vals[1], vals[2]
+
+	if order > LSBFirst || count > 8 || read > bitLen || uint64(len(rest))*8 != bitLen+uint64(count) {
+		return ErrBadFormat
+	}
+
+	stream := make([]byte, len(rest))
+	copy(stream, rest)
+
+	b.stream = stream
+	b.count = count
+	b.order = order
+	b.bitsWritten = int(written)
+	b.bitsRead = int64(read)
+	return nil
+}
+
+// unmarshalLegacy the old count byte + stream format
+func (b *BitStream) unmarshalLegacy(bIn []byte) error {
+	buf := bytes.NewReader(bIn)
+	err := binary.Read(buf, binary.BigEndian, &b.count)
+	if err != nil {
+		return err
+	}
+	b.stream = make([]byte, buf.Len())
+	err = binary.Read(buf, binary.BigEndian, &b.stream)
+	if err != nil {
+		return err
+	}
+	if len(b.stream) == 0 && b.count > 0 {
+		b.count = 0
+	}
+	b.order = MSBFirst
+	b.bitsWritten = int(b.validBits())
+	b.bitsRead = 0
+	return nil
+}
@@ -0,0 +1,78 @@
+package bitstream
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	b := NewBWriter(0)
+	writeTestBits(b)
+	b.WriteBits(0x3, 3)
+	if _, err := b.ReadBits(testBits[0].nbits); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	bin, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	n := new(BitStream)
+	if err := n.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !bytes.Equal(n.Bytes(), b.Bytes()) || n.validBits() != b.validBits() || n.Position() != b.Position() || n.bitsWritten != b.bitsWritten {
+		t.Fatalf("unmarshaled stream does not match")
+	}
+
+	// carry on where we left off
+	n.WriteBit(OneBit)
+	b.WriteBit(OneBit)
+	if !bytes.Equal(n.Bytes(), b.Bytes()) {
+		t.Fatalf("writes after unmarshal differ")
+	}
+	for i := 1; i < len(testBits); i++ {
+		u, err := n.ReadBits(testBits[i].nbits)
+		if err != nil || u != testBits[i].u {
+			t.Fatalf("read %d after unmarshal got %x (%v) wanted %x", i, u, err, testBits[i].u)
+		}
+	}
+
+	// every truncation fails
+	for i := 0; i < len(bin); i++ {
+		if err := new(BitStream).UnmarshalBinary(bin[:i]); err == nil {
+			t.Fatalf("truncated to %d bytes did not fail", i)
+		}
+	}
+
+	// every single bit flip fails (other then ones that turn the magic into a
+	// legacy count byte, that format has nothing to check)
+	for i := 0; i < len(bin)*8; i++ {
+		c := append([]byte{}, bin...)
+		c[i/8] ^= 1 << uint(i%8)
+		if c[0] <= 8 {
+			continue
+		}
+		if err := new(BitStream).UnmarshalBinary(c); err == nil {
+			t.Fatalf("bit flip %d did not fail", i)
+		}
+	}
+}
+
+func TestUnmarshalBinaryLegacy(t *testing.T) {
+	b := NewBWriter(0)
+	writeTestBits(b)
+
+	// the old format, count then the raw bytes
+	legacy := append([]byte{b.count}, b.Bytes()...)
+
+	n := new(BitStream)
+	if err := n.UnmarshalBinary(legacy); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !bytes.Equal(n.Bytes(), b.Bytes()) || n.validBits() != b.validBits() {
+		t.Fatalf("unmarshaled stream does not match")
+	}
+	readTestBits(t, n)
+}
@@ -0,0 +1,288 @@
+package bitstream
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Struct tag driven bit fields
+
+	type Header struct {
+		Version uint8     `bits:"3"`
+		Flag    bool      // 1 bit
+		Delta   int16     `bits:"13,signed"`
+		_       uint8     `bits:"5"`  // padding, zeros on write skipped on read
+		Sizes   [4]uint16 `bits:"12"` // the width is per element
+		Inner   Other     // nested structs are walked in order
+		Ignored string    `bits:"-"`
+	}
+
+Fields are written/read in order, MSB first unless the stream says otherwise.
+With no tag a field takes its natural size (bool is 1 bit, int16 16 bits ...),
+uint/int/uintptr are 64 bits. Unexported fields (other then _ padding) are skipped.
+
+An int field narrower then its type is unsigned unless tagged signed (two's complement
+in the given bits), at its full width it is always two's complement.
+Floats can only be stored at their full width.
+*/
+
+// ErrUnsupportedType the value (or a field of it) can not be a bit field
+var ErrUnsupportedType = errors.New("bitstream: unsupported bit field type")
+
+// ErrBadTag the bits tag could not be parsed or does not fit the field type
+var ErrBadTag = errors.New("bitstream: bad bits tag")
+
+// ErrFieldOverflow the field value does not fit in its bits
+var ErrFieldOverflow = errors.New("bitstream: value does not fit in the bit field")
+
+// FieldError a problem with a single field, Field is the path to it (Inner.Sizes)
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error the error string
+func (e *FieldError) Error() string {
+	return "bitstream: field " + e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// bitField how a (non struct) field is stored
+type bitField struct {
+	bits   int
+	signed bool
+}
+
+// Marshal write the fields of the struct (or pointer to struct) v to w, if an
+// error is returned part of v may already have been written
+func Marshal(w BitsWriter, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return ErrUnsupportedType
+	}
+	return marshalStruct(w, rv)
+}
+
+// Unmarshal read the fields of the struct pointed to by v from r
+func Unmarshal(r BitsReader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrUnsupportedType
+	}
+	return unmarshalStruct(r, rv.Elem())
+}
+
+// fieldError add the field name to the front of the path
+func fieldError(name string, err error) error {
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		return &FieldError{Field: name + "." + fe.Field, Err: fe.Err}
+	}
+	return &FieldError{Field: name, Err: err}
+}
+
+// skipField unexported fields are skipped, _ is padding
+func skipField(sf reflect.StructField) bool {
+	return sf.Tag.Get("bits") == "-" || (sf.PkgPath != "" && sf.Name != "_")
+}
+
+func marshalStruct(w BitsWriter, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if skipField(sf) {
+			continue
+		}
+		f, err := parseBitTag(sf.Tag.Get("bits"), sf.Type)
+		if err != nil {
+			return fieldError(sf.Name, err)
+		}
+		fv := rv.Field(i)
+		if sf.Name == "_" {
+			fv = reflect.Zero(sf.Type)
+		}
+		if err := marshalValue(w, fv, f); err != nil {
+			return fieldError(sf.Name, err)
+		}
+	}
+	return nil
+}
+
+func unmarshalStruct(r BitsReader, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if skipField(sf) {
+			continue
+		}
+		f, err := parseBitTag(sf.Tag.Get("bits"), sf.Type)
+		if err != nil {
+			return fieldError(sf.Name, err)
+		}
+		var fv reflect.Value
+		if sf.Name == "_" {
+			fv = reflect.New(sf.Type).Elem()
+		} else {
+			fv = rv.Field(i)
+		}
+		if err := unmarshalValue(r, fv, f); err != nil {
+			return fieldError(sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// parseBitTag the bit field for the tag on a field of type t (the element type for arrays)
+func parseBitTag(tag string, t reflect.Type) (bitField, error) {
+	for t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	var f bitField
+	switch t.Kind() {
+	case reflect.Struct:
+		if tag != "" {
+			return f, ErrBadTag
+		}
+		return f, nil
+	case reflect.Bool:
+		f.bits = 1
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		f.bits = t.Bits()
+	default:
+		return f, ErrUnsupportedType
+	}
+
+	if tag != "" {
+		opts := strings.Split(tag, ",")
+		if opts[0] != "" {
+			n, err := strconv.Atoi(opts[0])
+			if err != nil || n < 1 || n > 64 {
+				return f, ErrBadTag
+			}
+			if t.Kind() != reflect.Bool && n > f.bits {
+				return f, ErrBadTag
+			}
+			if (t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64) && n != f.bits {
+				return f, ErrBadTag
+			}
+			f.bits = n
+		}
+		for _, o := range opts[1:] {
+			switch strings.TrimSpace(o) {
+			case "signed":
+				switch t.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+					f.signed = true
+				default:
+					return f, ErrBadTag
+				}
+			default:
+				return f, ErrBadTag
+			}
+		}
+	}
+
+	// ints at full width are two's complement either way
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.bits == t.Bits() {
+			f.signed = true
+		}
+	}
+	return f, nil
+}
+
+func marshalValue(w BitsWriter, v reflect.Value, f bitField) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		return marshalStruct(w, v)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := marshalValue(w, v.Index(i), f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Bool:
+		var u uint64
+		if v.Bool() {
+			u = 1
+		}
+		w.WriteBits(u, f.bits)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x := v.Int()
+		if f.bits < 64 {
+			if f.signed {
+				if lim := int64(1) << uint(f.bits-1); x < -lim || x >= lim {
+					return ErrFieldOverflow
+				}
+			} else if x < 0 || x>>uint(f.bits) != 0 {
+				return ErrFieldOverflow
+			}
+		}
+		w.WriteBits(uint64(x), f.bits)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if f.bits < 64 && u>>uint(f.bits) != 0 {
+			return ErrFieldOverflow
+		}
+		w.WriteBits(u, f.bits)
+	case reflect.Float32:
+		w.WriteBits(uint64(math.Float32bits(float32(v.Float()))), 32)
+	case reflect.Float64:
+		w.WriteBits(math.Float64bits(v.Float()), 64)
+	default:
+		return ErrUnsupportedType
+	}
+	return nil
+}
+
+func unmarshalValue(r BitsReader, v reflect.Value, f bitField) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		return unmarshalStruct(r, v)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := unmarshalValue(r, v.Index(i), f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	u, err := r.ReadBits(f.bits)
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(u != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x := int64(u)
+		if f.signed {
+			shift := uint(64 - f.bits)
+			x = int64(u<<shift) >> shift
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(u)
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(uint32(u))))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(u))
+	default:
+		return ErrUnsupportedType
+	}
+	return nil
+}
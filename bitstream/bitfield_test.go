@@ -0,0 +1,120 @@
+package bitstream
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testInner struct {
+	A uint8 `bits:"4"`
+	B bool
+}
+
+type testHeader struct {
+	Version uint8 `bits:"3"`
+	Flag    bool
+	Delta   int16     `bits:"13,signed"`
+	_       uint8     `bits:"5"`
+	Sizes   [3]uint16 `bits:"12"`
+	Inner   [2]testInner
+	Full    int8
+	Ratio   float32
+	Name    string `bits:"-"`
+	private int
+}
+
+func TestBitFieldRoundTrip(t *testing.T) {
+	h := testHeader{
+		Version: 5,
+		Flag:    true,
+		Delta:   -4000,
+		Sizes:   [3]uint16{1, 0xfff, 0x800},
+		Inner:   [2]testInner{{A: 0xa, B: true}, {A: 3}},
+		Full:    -128,
+		Ratio:   0.5,
+		Name:    "not written",
+		private: 7,
+	}
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		b := NewBWriter(16)
+		b.SetOrder(order)
+		if err := Marshal(b, &h); err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		if got, want := b.BitLen(), int64(3+1+13+5+3*12+2*5+8+32); got != want {
+			t.Fatalf("wrote %d bits wanted %d", got, want)
+		}
+
+		var g testHeader
+		if err := Unmarshal(b, &g); err != nil {
+			t.Fatalf("unmarshal failed: %v", err)
+		}
+		want := h
+		want.Name, want.private = "", 0
+		if !reflect.DeepEqual(g, want) {
+			t.Fatalf("round trip mismatch: %+v != %+v", g, want)
+		}
+	}
+}
+
+func TestBitFieldEncoding(t *testing.T) {
+	v := struct {
+		A uint8 `bits:"3"`
+		B int8  `bits:"4,signed"`
+		_ bool
+	}{A: 5, B: -2}
+	w := NewWriter(1)
+	if err := Marshal(w, v); err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	// 101 1110 0
+	if w.Bytes()[0] != 0xbc {
+		t.Fatalf("got %x wanted bc", w.Bytes()[0])
+	}
+}
+
+func TestBitFieldErrors(t *testing.T) {
+	w := NewWriter(4)
+	if err := Marshal(w, struct {
+		In struct {
+			A uint8 `bits:"3"`
+		}
+	}{In: struct {
+		A uint8 `bits:"3"`
+	}{A: 8}}); !errors.Is(err, ErrFieldOverflow) {
+		t.Fatalf("expected ErrFieldOverflow got %v", err)
+	} else if fe := (*FieldError)(nil); !errors.As(err, &fe) || fe.Field != "In.A" {
+		t.Fatalf("expected the field path In.A got %v", err)
+	}
+
+	if err := Marshal(w, struct {
+		A int8 `bits:"3,signed"`
+	}{A: -5}); !errors.Is(err, ErrFieldOverflow) {
+		t.Fatalf("expected ErrFieldOverflow got %v", err)
+	}
+	if err := Marshal(w, struct {
+		A uint8 `bits:"9"`
+	}{}); !errors.Is(err, ErrBadTag) {
+		t.Fatalf("expected ErrBadTag got %v", err)
+	}
+	if err := Marshal(w, struct {
+		A uint8 `bits:"3,signed"`
+	}{}); !errors.Is(err, ErrBadTag) {
+		t.Fatalf("expected ErrBadTag got %v", err)
+	}
+	if err := Marshal(w, struct{ A []int }{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType got %v", err)
+	}
+	if err := Marshal(w, 1); err != ErrUnsupportedType {
+		t.Fatalf("expected ErrUnsupportedType got %v", err)
+	}
+
+	var h testHeader
+	if err := Unmarshal(w.Reader(), h); err != ErrUnsupportedType {
+		t.Fatalf("expected ErrUnsupportedType got %v", err)
+	}
+	if err := Unmarshal(NewReader([]byte{0xff}), &h); !errors.Is(err, ErrShortRead) {
+		t.Fatalf("expected ErrShortRead got %v", err)
+	}
+}
@@ -3,17 +3,14 @@ shamelessly adapted from https://github.com/dgryski/go-tsz/blob/master/bstream.g
 
 But modified to have public methods and few other addons.
 
+BitStream is the original read+write stream, Writer and Reader are the write only
+and read only halves of it (many Readers can share the same block of bytes)
+
 Note: this is not a thread safe implementation, locking should be done externally
 */
 
 package bitstream
 
-import (
-	"bytes"
-	"encoding/binary"
-	"io"
-)
-
 // Bit a single bit type
 type Bit bool
 
@@ -24,256 +21,155 @@ const (
 	OneBit Bit = true
 )
 
-// BitStream is a stream of bits
-type BitStream struct {
-	// the data stream
-	stream []byte
+// BitOrder the order bits are packed into each byte
+type BitOrder uint8
+
+const (
+	// MSBFirst fill bytes from the high bit down, multi bit values go high bit first (TSZ, most "network" formats)
+	MSBFirst BitOrder = iota
+	// LSBFirst fill bytes from the low bit up, multi bit values go low bit first (DEFLATE, LZ-ish formats)
+	LSBFirst
+)
+
+// String the name of the order
+func (o BitOrder) String() string {
+	if o == LSBFirst {
+		return "LSBFirst"
+	}
+	return "MSBFirst"
+}
 
-	// how many bits are valid in current byte
-	count uint8
+// BitsWriter anything that bits can be written to (BitStream, Writer, BitWriter)
+type BitsWriter interface {
+	WriteBit(bit Bit)
+	WriteBits(u uint64, nbits int)
+}
 
-	bitsWritten int
-	bitsRead    int64
+// BitsReader anything that bits can be read from (BitStream, Reader, BitReader)
+type BitsReader interface {
+	ReadBit() (Bit, error)
+	ReadBits(nbits int) (uint64, error)
+}
+
+var (
+	_ BitsWriter = (*BitStream)(nil)
+	_ BitsWriter = (*Writer)(nil)
+	_ BitsWriter = (*BitWriter)(nil)
+	_ BitsReader = (*BitStream)(nil)
+	_ BitsReader = (*Reader)(nil)
+	_ BitsReader = (*BitReader)(nil)
+)
+
+// BitStream is a stream of bits, a Writer with a read cursor
+//
+// Reading does not modify the stream, it just moves the cursor, see Reader
+// and Writer for the read only and write only versions
+type BitStream struct {
+	Writer
+
+	bitsRead int64
+	strict   bool
 }
 
 // NewBReader new bit stream reader
 func NewBReader(b []byte) *BitStream {
-	return &BitStream{stream: b, count: 8, bitsWritten: 0}
+	return &BitStream{Writer: Writer{stream: b, count: 0, bitsWritten: 0}}
 }
 
 // NewBWriter new bit stream writer
 func NewBWriter(size int) *BitStream {
-	return &BitStream{stream: make([]byte, 0, size), count: 0, bitsWritten: 0}
+	return &BitStream{Writer: Writer{stream: make([]byte, 0, size), count: 0, bitsWritten: 0}}
 }
 
-// SetStream reset a bitstream from some other byte slice
+// SetStream reset a bitstream from some other byte slice, all of bs is readable
 func (b *BitStream) SetStream(bs []byte) {
 	b.stream = bs
+	b.count = 0
+	b.bitsRead = 0
 }
 
-// Len length in Bytes of the stream (not thread safe)
-func (b *BitStream) Len() int {
-	return int(b.bitsWritten / 8)
+// Reset empty the stream and rewind the read cursor keeping the underlying buffer
+func (b *BitStream) Reset() {
+	b.Writer.Reset()
+	b.bitsRead = 0
 }
 
-// Clone a BitStream in to a new one
+// Clone a BitStream in to a new one, the read cursor included
 func (b *BitStream) Clone() *BitStream {
-	d := make([]byte, len(b.stream))
-	copy(d, b.stream)
-	return &BitStream{stream: d, count: b.count, bitsWritten: len(d)}
+	return &BitStream{Writer: *b.Writer.Clone(), bitsRead: b.bitsRead, strict: b.strict}
 }
 
-// Bytes the stream as it stands
-func (b *BitStream) Bytes() []byte {
-	return b.stream
+// Append the bits of other (all of them, not just the unread ones) onto the end of
+// this stream, see Writer.Append
+func (b *BitStream) Append(other *BitStream) {
+	b.Writer.Append(&other.Writer)
 }
 
-// WriteBit write a single bit
-func (b *BitStream) WriteBit(bit Bit) {
-
-	if b.count == 0 {
-		b.stream = append(b.stream, 0)
-		b.count = 8
-	}
-
-	i := len(b.stream) - 1
-
-	if bit {
-		b.stream[i] |= 1 << (b.count - 1)
-	}
-	b.bitsWritten++
-	b.count--
+// reader the read cursor as a Reader
+func (b *BitStream) reader() Reader {
+	return Reader{stream: b.stream, pos: b.bitsRead, end: b.validBits(), order: b.order, strict: b.strict}
 }
 
-// WriteBytes write a byte slice
-func (b *BitStream) WriteBytes(bs []byte) int {
-	c := 0
-	for _, by := range bs {
-		b.WriteByte(by)
-		c++
-	}
-	return c
+// SetStrict see Reader.SetStrict
+func (b *BitStream) SetStrict(strict bool) {
+	b.strict = strict
 }
 
-// WriteByte write a byte to the stream
-func (b *BitStream) WriteByte(byt byte) error {
-
-	if b.count == 0 {
-		b.stream = append(b.stream, 0)
-		b.count = 8
-	}
-
-	i := len(b.stream) - 1
-
-	// fill up b.b with b.count bits from byt
-	b.stream[i] |= byt >> (8 - b.count)
+// Position the bit offset of the next read
+func (b *BitStream) Position() int64 {
+	return b.bitsRead
+}
 
-	b.stream = append(b.stream, 0)
-	i++
-	b.stream[i] = byt << b.count
-	b.bitsWritten += 8
-	return nil
+// SeekBit move the read cursor to bit offset pos
+func (b *BitStream) SeekBit(pos int64) error {
+	r := b.reader()
+	err := r.SeekBit(pos)
+	b.bitsRead = r.pos
+	return err
 }
 
-// WriteBits write nbits to the stream
-func (b *BitStream) WriteBits(u uint64, nbits int) {
-	u <<= (64 - uint(nbits))
-	for nbits >= 8 {
-		byt := byte(u >> 56)
-		b.WriteByte(byt)
-		u <<= 8
-		nbits -= 8
-	}
+// SkipBits move the read cursor forward nbits
+func (b *BitStream) SkipBits(nbits int64) error {
+	r := b.reader()
+	err := r.SkipBits(nbits)
+	b.bitsRead = r.pos
+	return err
+}
 
-	for nbits > 0 {
-		b.WriteBit((u >> 63) == 1)
-		u <<= 1
-		nbits--
-	}
-	b.bitsWritten += nbits
+// PeekBits read nbits without moving the read cursor
+func (b *BitStream) PeekBits(nbits int) (uint64, error) {
+	r := b.reader()
+	return r.PeekBits(nbits)
 }
 
 // ReadBit read a single bit
 func (b *BitStream) ReadBit() (Bit, error) {
-
-	if len(b.stream) == 0 {
-		return false, io.EOF
-	}
-
-	if b.count == 0 {
-		b.stream = b.stream[1:]
-		// did we just run out of stuff to read?
-		if len(b.stream) == 0 {
-			return false, io.EOF
-		}
-		b.count = 8
-	}
-
-	b.count--
-	d := b.stream[0] & 0x80
-	b.stream[0] <<= 1
-	b.bitsRead++
-	return d != 0, nil
+	r := b.reader()
+	bit, err := r.ReadBit()
+	b.bitsRead = r.pos
+	return bit, err
 }
 
 // ReadBytes read n bytes from the stream
 func (b *BitStream) ReadBytes(n uint8) ([]byte, error) {
-
-	if len(b.stream) == 0 {
-		return nil, io.EOF
-	}
-	if len(b.stream) < int(n) {
-		return nil, io.EOF
-	}
-
-	byts := make([]byte, n)
-	var err error
-	for i := uint8(0); i < n; i++ {
-		byts[i], err = b.ReadByte()
-		if err != nil {
-			return nil, err
-		}
-	}
-	return byts, nil
-
+	r := b.reader()
+	byts, err := r.ReadBytes(int(n))
+	b.bitsRead = r.pos
+	return byts, err
 }
 
 // ReadByte read a byte from the stream
 func (b *BitStream) ReadByte() (byte, error) {
-
-	if len(b.stream) == 0 {
-		return 0, io.EOF
-	}
-
-	if b.count == 0 {
-		b.stream = b.stream[1:]
-
-		if len(b.stream) == 0 {
-			return 0, io.EOF
-		}
-
-		b.count = 8
-	}
-
-	if b.count == 8 {
-		b.count = 0
-		b.bitsRead += 8
-		return b.stream[0], nil
-		//b.stream = b.stream[1:]
-		//return byt, nil
-	}
-
-	byt := b.stream[0]
-	b.stream = b.stream[1:]
-
-	if len(b.stream) == 0 {
-		return 0, io.EOF
-	}
-
-	byt |= b.stream[0] >> b.count
-	b.stream[0] <<= (8 - b.count)
-
-	b.bitsRead += 8
-	return byt, nil
+	r := b.reader()
+	byt, err := r.ReadByte()
+	b.bitsRead = r.pos
+	return byt, err
 }
 
 // ReadBits read nbits from the stream
 func (b *BitStream) ReadBits(nbits int) (uint64, error) {
-
-	var u uint64
-
-	for nbits >= 8 {
-		byt, err := b.ReadByte()
-		if err != nil {
-			return 0, err
-		}
-
-		u = (u << 8) | uint64(byt)
-		nbits -= 8
-	}
-
-	var err error
-	for nbits > 0 && err != io.EOF {
-		byt, err := b.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		u <<= 1
-		if byt {
-			u |= 1
-		}
-		nbits--
-	}
-	b.bitsRead += int64(nbits)
-
-	return u, nil
-}
-
-// MarshalBinary implements the encoding.BinaryMarshaler interface
-func (b *BitStream) MarshalBinary() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.BigEndian, b.count)
-	if err != nil {
-		return nil, err
-	}
-	err = binary.Write(buf, binary.BigEndian, b.stream)
-	if err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
-}
-
-// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface
-func (b *BitStream) UnmarshalBinary(bIn []byte) error {
-	buf := bytes.NewReader(bIn)
-	err := binary.Read(buf, binary.BigEndian, &b.count)
-	if err != nil {
-		return err
-	}
-	b.stream = make([]byte, buf.Len())
-	err = binary.Read(buf, binary.BigEndian, &b.stream)
-	if err != nil {
-		return err
-	}
-	return nil
+	r := b.reader()
+	u, err := r.ReadBits(nbits)
+	b.bitsRead = r.pos
+	return u, err
 }
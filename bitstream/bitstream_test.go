@@ -0,0 +1,255 @@
+package bitstream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+type bitsVal struct {
+	u     uint64
+	nbits int
+}
+
+var testBits = []bitsVal{
+	{1, 1},
+	{0x5, 3},
+	{0xab, 8},
+	{0x1234, 13},
+	{0, 7},
+	{0xdeadbeefcafe, 48},
+	{0xffffffffffffffff, 64},
+	{1, 2},
+	{0x7f, 7},
+}
+
+func writeTestBits(w interface {
+	WriteBits(uint64, int)
+}) {
+	for _, v := range testBits {
+		w.WriteBits(v.u, v.nbits)
+	}
+}
+
+func checkTestBits(r interface {
+	ReadBits(int) (uint64, error)
+}) error {
+	for i, v := range testBits {
+		u, err := r.ReadBits(v.nbits)
+		if err != nil {
+			return fmt.Errorf("read %d failed: %v", i, err)
+		}
+		if u != v.u {
+			return fmt.Errorf("read %d mismatch: got %x wanted %x", i, u, v.u)
+		}
+	}
+	return nil
+}
+
+func readTestBits(t *testing.T, r interface {
+	ReadBits(int) (uint64, error)
+}) {
+	if err := checkTestBits(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBitStreamReadWrite(t *testing.T) {
+	b := NewBWriter(16)
+	writeTestBits(b)
+	readTestBits(t, b)
+
+	r := NewBReader(b.Bytes())
+	orig := append([]byte{}, b.Bytes()...)
+	readTestBits(t, r)
+
+	// reading must leave the bytes alone
+	if !bytes.Equal(orig, b.Bytes()) {
+		t.Fatalf("reading modified the stream")
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	w := NewWriter(16)
+	writeTestBits(w)
+
+	bs := NewBWriter(16)
+	writeTestBits(bs)
+	if !bytes.Equal(w.Bytes(), bs.Bytes()) {
+		t.Fatalf("Writer and BitStream encodings differ")
+	}
+
+	orig := append([]byte{}, w.Bytes()...)
+
+	// many readers on the same block at once
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = checkTestBits(NewReader(w.Bytes()))
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := w.Reader()
+	readTestBits(t, r)
+	if _, err := r.ReadBit(); err != io.EOF {
+		t.Fatalf("expected io.EOF at the end of the written bits got %v", err)
+	}
+
+	if !bytes.Equal(orig, w.Bytes()) {
+		t.Fatalf("reading modified the stream")
+	}
+}
+
+func TestReaderShort(t *testing.T) {
+	r := NewReaderBits([]byte{0xff, 0xff}, 12)
+	if _, err := r.ReadBits(13); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+	if u, err := r.ReadBits(12); err != nil || u != 0xfff {
+		t.Fatalf("short read consumed bits: %x %v", u, err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+}
+
+func TestBitStreamClone(t *testing.T) {
+	b := NewBWriter(4)
+	b.WriteBits(0x5, 3)
+	b.WriteByte(0xab)
+	if b.BitLen() != 11 || b.Len() != 2 {
+		t.Fatalf("got %d bits %d bytes wanted 11 and 2", b.BitLen(), b.Len())
+	}
+	b.ReadBits(3)
+
+	c := b.Clone()
+	if c.BitLen() != 11 || c.bitsWritten != b.bitsWritten || c.Position() != 3 {
+		t.Fatalf("clone lost the counts: %d bits %d written at %d", c.BitLen(), c.bitsWritten, c.Position())
+	}
+	c.WriteBits(0x1f, 5)
+	if b.BitLen() != 11 {
+		t.Fatalf("writing to the clone changed the original")
+	}
+	if u, err := c.ReadBits(13); err != nil || u != 0xab<<5|0x1f {
+		t.Fatalf("clone read failed: %x %v", u, err)
+	}
+}
+
+func TestBitStreamAppend(t *testing.T) {
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		for lead := 0; lead < 9; lead++ {
+			for _, otherOrder := range []BitOrder{MSBFirst, LSBFirst} {
+				b := NewBWriter(0)
+				b.SetOrder(order)
+				b.WriteBits(0x1ff, lead)
+
+				o := NewBWriter(0)
+				o.SetOrder(otherOrder)
+				writeTestBits(o)
+				o.ReadBits(10) // the read cursor does not matter
+
+				b.Append(o)
+				if b.BitLen() != int64(lead)+o.BitLen() {
+					t.Fatalf("appended length %d wanted %d", b.BitLen(), int64(lead)+o.BitLen())
+				}
+				if u, err := b.ReadBits(lead); err != nil || u != 0x1ff>>uint(9-lead) {
+					t.Fatalf("lead bits mismatch %x %v", u, err)
+				}
+				if order == otherOrder {
+					readTestBits(t, b)
+					continue
+				}
+				// the bits come across in the same sequence
+				o.SeekBit(0)
+				for {
+					want, err := o.ReadBit()
+					if err != nil {
+						break
+					}
+					if got, err := b.ReadBit(); err != nil || got != want {
+						t.Fatalf("bit %d mismatch %v", o.Position()-1, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBitStreamAppendBlocks(t *testing.T) {
+	// build blocks in parallel then splice them together
+	blocks := make([]*BitStream, 8)
+	var wg sync.WaitGroup
+	for i := range blocks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			blocks[i] = NewBWriter(0)
+			for j := 0; j <= i; j++ {
+				WriteExpGolomb(blocks[i], uint64(i*100+j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	b := NewBWriter(0)
+	for _, blk := range blocks {
+		b.Append(blk)
+	}
+	b.Append(b) // and itself
+	for k := 0; k < 2; k++ {
+		for i := range blocks {
+			for j := 0; j <= i; j++ {
+				if v, err := ReadExpGolomb(b); err != nil || v != uint64(i*100+j) {
+					t.Fatalf("block %d value %d: %d %v", i, j, v, err)
+				}
+			}
+		}
+	}
+	if _, err := b.ReadBit(); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+}
+
+func TestBitStreamReset(t *testing.T) {
+	b := NewBWriter(64)
+	writeTestBits(b)
+	readTestBits(t, b)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		b.Reset()
+		writeTestBits(b)
+		if err := checkTestBits(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("reset and reuse allocated %v times", allocs)
+	}
+	b.Reset()
+	if b.BitLen() != 0 || b.Position() != 0 || b.Len() != 0 {
+		t.Fatalf("reset left %d bits at %d", b.BitLen(), b.Position())
+	}
+
+	r := NewReader(nil)
+	r.Reset(b.Bytes())
+	if _, err := r.ReadBit(); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+	b.WriteByte(0xa5)
+	r.Reset(b.Bytes()[:1])
+	if u, err := r.ReadByte(); err != nil || u != 0xa5 {
+		t.Fatalf("reset reader read %x %v", u, err)
+	}
+}
@@ -0,0 +1,273 @@
+package bitstream
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+/*
+Variable length integer codes
+
+	Exp-Golomb (order 0): n zeros, a one, then the low n bits of v+1 (n = bit length of v+1 - 1)
+	    covers all of uint64 (MaxUint64 is 64 zeros, a one and 64 more zeros)
+	Signed Exp-Golomb: Exp-Golomb of the zig-zag encoded value
+	Elias gamma: n zeros then v in n+1 bits (v > 0)
+	Elias delta: gamma of the bit length of v then the low bits of v after the leading one (v > 0)
+	Rice(k): v >> k in unary (ones ended by a zero) then the low k bits
+	Golomb(m): v / m in unary then v % m in truncated binary
+
+Rice and Golomb codes have a unary part that grows with v/m, so pick k/m to match the
+values being coded, a MaxUint64 with k = 0 is a LOT of bits
+*/
+
+// ErrZeroValue Elias gamma and delta codes cannot represent zero
+var ErrZeroValue = errors.New("bitstream: value must be greater then zero")
+
+// ErrInvalidParameter bad Rice k or Golomb m
+var ErrInvalidParameter = errors.New("bitstream: invalid code parameter")
+
+// ErrInvalidCode the bits read are not a valid code
+var ErrInvalidCode = errors.New("bitstream: invalid code")
+
+// ZigZag map signed integers to unsigned ones so small magnitudes stay small
+// 0 -> 0, -1 -> 1, 1 -> 2, -2 -> 3 ...
+func ZigZag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+// UnZigZag the reverse of ZigZag
+func UnZigZag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// writeUnary q ones followed by a zero
+func writeUnary(w BitsWriter, q uint64) {
+	for ; q >= 64; q -= 64 {
+		w.WriteBits(math.MaxUint64, 64)
+	}
+	w.WriteBits(1<<q-1, int(q))
+	w.WriteBit(ZeroBit)
+}
+
+// readZeros count zero bits up to (and eating) the next one bit, at most max zeros
+func readZeros(r BitsReader, max int) (int, error) {
+	n := 0
+	for {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit {
+			return n, nil
+		}
+		n++
+		if n > max {
+			return 0, ErrInvalidCode
+		}
+	}
+}
+
+// readUnary count one bits up to (and eating) the next zero bit
+func readUnary(r BitsReader) (uint64, error) {
+	var q uint64
+	for {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			return q, nil
+		}
+		q++
+		if q == 0 {
+			return 0, ErrInvalidCode
+		}
+	}
+}
+
+// WriteExpGolomb write v as an order 0 Exp-Golomb code
+func WriteExpGolomb(w BitsWriter, v uint64) {
+	// v+1 wraps to 0 for MaxUint64, it's the only 65 bit value
+	n := 64
+	if v != math.MaxUint64 {
+		n = bits.Len64(v+1) - 1
+	}
+	w.WriteBits(0, n)
+	w.WriteBit(OneBit)
+	w.WriteBits(v+1, n)
+}
+
+// ReadExpGolomb read an order 0 Exp-Golomb code
+func ReadExpGolomb(r BitsReader) (uint64, error) {
+	n, err := readZeros(r, 64)
+	if err != nil {
+		return 0, err
+	}
+	u, err := r.ReadBits(n)
+	if err != nil {
+		return 0, err
+	}
+	if n == 64 && u != 0 {
+		// past MaxUint64
+		return 0, ErrInvalidCode
+	}
+	// (1<<64) is 0 in uint64 land, and the wrap around gives the right answer
+	return (uint64(1)<<uint(n) | u) - 1, nil
+}
+
+// WriteSignedExpGolomb write v as a zig-zag mapped order 0 Exp-Golomb code
+func WriteSignedExpGolomb(w BitsWriter, v int64) {
+	WriteExpGolomb(w, ZigZag(v))
+}
+
+// ReadSignedExpGolomb read a zig-zag mapped order 0 Exp-Golomb code
+func ReadSignedExpGolomb(r BitsReader) (int64, error) {
+	u, err := ReadExpGolomb(r)
+	if err != nil {
+		return 0, err
+	}
+	return UnZigZag(u), nil
+}
+
+// WriteEliasGamma write v (> 0) as an Elias gamma code
+func WriteEliasGamma(w BitsWriter, v uint64) error {
+	if v == 0 {
+		return ErrZeroValue
+	}
+	n := bits.Len64(v) - 1
+	w.WriteBits(0, n)
+	w.WriteBits(v, n+1)
+	return nil
+}
+
+// ReadEliasGamma read an Elias gamma code
+func ReadEliasGamma(r BitsReader) (uint64, error) {
+	n, err := readZeros(r, 63)
+	if err != nil {
+		return 0, err
+	}
+	u, err := r.ReadBits(n)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(1)<<uint(n) | u, nil
+}
+
+// WriteEliasDelta write v (> 0) as an Elias delta code
+func WriteEliasDelta(w BitsWriter, v uint64) error {
+	if v == 0 {
+		return ErrZeroValue
+	}
+	n := bits.Len64(v)
+	WriteEliasGamma(w, uint64(n))
+	w.WriteBits(v, n-1)
+	return nil
+}
+
+// ReadEliasDelta read an Elias delta code
+func ReadEliasDelta(r BitsReader) (uint64, error) {
+	n, err := ReadEliasGamma(r)
+	if err != nil {
+		return 0, err
+	}
+	if n > 64 {
+		return 0, ErrInvalidCode
+	}
+	u, err := r.ReadBits(int(n - 1))
+	if err != nil {
+		return 0, err
+	}
+	return uint64(1)<<uint(n-1) | u, nil
+}
+
+// WriteRice write v as a Rice code with parameter k (0 <= k <= 64)
+func WriteRice(w BitsWriter, v uint64, k uint) error {
+	if k > 64 {
+		return ErrInvalidParameter
+	}
+	writeUnary(w, v>>k)
+	w.WriteBits(v, int(k))
+	return nil
+}
+
+// ReadRice read a Rice code with parameter k
+func ReadRice(r BitsReader, k uint) (uint64, error) {
+	if k > 64 {
+		return 0, ErrInvalidParameter
+	}
+	q, err := readUnary(r)
+	if err != nil {
+		return 0, err
+	}
+	if (k == 64 && q != 0) || (k < 64 && q > math.MaxUint64>>k) {
+		return 0, ErrInvalidCode
+	}
+	u, err := r.ReadBits(int(k))
+	if err != nil {
+		return 0, err
+	}
+	return q<<k | u, nil
+}
+
+// golombParams the number of bits for the remainder and the truncated binary cutoff
+func golombParams(m uint64) (int, uint64) {
+	b := bits.Len64(m - 1)
+	// (1<<64) - m wraps around to the right thing for b == 64
+	return b, uint64(1)<<uint(b) - m
+}
+
+// WriteGolomb write v as a Golomb code with parameter m (> 0)
+func WriteGolomb(w BitsWriter, v uint64, m uint64) error {
+	if m == 0 {
+		return ErrInvalidParameter
+	}
+	q, rem := v/m, v%m
+	writeUnary(w, q)
+
+	b, cutoff := golombParams(m)
+	if rem < cutoff {
+		w.WriteBits(rem, b-1)
+	} else {
+		w.WriteBits(rem+cutoff, b)
+	}
+	return nil
+}
+
+// ReadGolomb read a Golomb code with parameter m
+func ReadGolomb(r BitsReader, m uint64) (uint64, error) {
+	if m == 0 {
+		return 0, ErrInvalidParameter
+	}
+	q, err := readUnary(r)
+	if err != nil {
+		return 0, err
+	}
+
+	var rem uint64
+	if m > 1 {
+		b, cutoff := golombParams(m)
+		rem, err = r.ReadBits(b - 1)
+		if err != nil {
+			return 0, err
+		}
+		if rem >= cutoff {
+			bit, err := r.ReadBit()
+			if err != nil {
+				return 0, err
+			}
+			rem <<= 1
+			if bit {
+				rem |= 1
+			}
+			rem -= cutoff
+		}
+	}
+
+	hi, lo := bits.Mul64(q, m)
+	v, carry := bits.Add64(lo, rem, 0)
+	if hi != 0 || carry != 0 {
+		return 0, ErrInvalidCode
+	}
+	return v, nil
+}
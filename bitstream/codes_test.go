@@ -0,0 +1,203 @@
+package bitstream
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+// testUint64s edge values plus random values of every bit length
+func testUint64s() []uint64 {
+	vals := []uint64{0, 1, 2, 3, math.MaxUint64, math.MaxUint64 - 1, math.MaxUint32, math.MaxUint32 + 1}
+	rnd := rand.New(rand.NewSource(42))
+	for n := 1; n <= 64; n++ {
+		for i := 0; i < 8; i++ {
+			v := rnd.Uint64() >> uint(64-n)
+			v |= 1 << uint(n-1)
+			vals = append(vals, v, v-1)
+		}
+	}
+	return vals
+}
+
+// checkCode round trip vals then random values (mapped into range by in)
+func checkCode(t *testing.T, name string, vals []uint64, in func(uint64) uint64, write func(BitsWriter, uint64) error, read func(BitsReader) (uint64, error)) {
+	w := NewWriter(0)
+	for _, v := range vals {
+		if err := write(w, v); err != nil {
+			t.Fatalf("%s: write %d failed: %v", name, v, err)
+		}
+	}
+	r := w.Reader()
+	for _, v := range vals {
+		got, err := read(r)
+		if err != nil {
+			t.Fatalf("%s: read %d failed: %v", name, v, err)
+		}
+		if got != v {
+			t.Fatalf("%s: got %d wanted %d", name, got, v)
+		}
+	}
+
+	prop := func(v uint64) bool {
+		v = in(v)
+		w := NewWriter(0)
+		if err := write(w, v); err != nil {
+			return false
+		}
+		got, err := read(w.Reader())
+		return err == nil && got == v
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+func all(v uint64) uint64 { return v }
+
+func notZero(v uint64) uint64 {
+	if v == 0 {
+		return 1
+	}
+	return v
+}
+
+func TestExpGolomb(t *testing.T) {
+	checkCode(t, "expgolomb", testUint64s(), all,
+		func(w BitsWriter, v uint64) error { WriteExpGolomb(w, v); return nil },
+		ReadExpGolomb,
+	)
+
+	// known codes
+	w := NewWriter(0)
+	for _, v := range []uint64{0, 1, 2, 3} {
+		WriteExpGolomb(w, v)
+	}
+	// 1 010 011 00100
+	if u, _ := w.Reader().ReadBits(12); u != 0xa64 {
+		t.Fatalf("expgolomb bits: %b", u)
+	}
+
+	// 64 zeros is MaxUint64 with an all zero suffix, anything else is too big
+	w = NewWriter(0)
+	w.WriteBits(0, 64)
+	w.WriteBit(OneBit)
+	w.WriteBits(5, 64)
+	if _, err := ReadExpGolomb(w.Reader()); err != ErrInvalidCode {
+		t.Fatalf("expected ErrInvalidCode got %v", err)
+	}
+	if !bytes.Equal(w.Bytes()[:17], expGolombTooBig) {
+		t.Fatalf("fuzz seed out of date %x", w.Bytes())
+	}
+}
+
+func TestSignedExpGolomb(t *testing.T) {
+	vals := []int64{0, 1, -1, 2, -2, math.MaxInt64, math.MinInt64, math.MinInt64 + 1}
+	for _, u := range testUint64s() {
+		vals = append(vals, int64(u))
+	}
+	w := NewWriter(0)
+	for _, v := range vals {
+		WriteSignedExpGolomb(w, v)
+	}
+	r := w.Reader()
+	for _, v := range vals {
+		got, err := ReadSignedExpGolomb(r)
+		if err != nil || got != v {
+			t.Fatalf("signed expgolomb: got %d (%v) wanted %d", got, err, v)
+		}
+	}
+	prop := func(v int64) bool {
+		return UnZigZag(ZigZag(v)) == v
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEliasGammaDelta(t *testing.T) {
+	var vals []uint64
+	for _, v := range testUint64s() {
+		if v != 0 {
+			vals = append(vals, v)
+		}
+	}
+	checkCode(t, "gamma", vals, notZero, WriteEliasGamma, ReadEliasGamma)
+	checkCode(t, "delta", vals, notZero, WriteEliasDelta, ReadEliasDelta)
+
+	w := NewWriter(0)
+	if WriteEliasGamma(w, 0) != ErrZeroValue || WriteEliasDelta(w, 0) != ErrZeroValue {
+		t.Fatalf("zero should not be encodable")
+	}
+}
+
+func TestRice(t *testing.T) {
+	for _, k := range []uint{0, 1, 5, 31, 63, 64} {
+		k := k
+		// the unary part is v >> k, keep it sane
+		small := func(v uint64) uint64 {
+			if k < 54 {
+				return v & (1<<(k+10) - 1)
+			}
+			return v
+		}
+		var vals []uint64
+		for _, v := range testUint64s() {
+			if small(v) == v {
+				vals = append(vals, v)
+			}
+		}
+		checkCode(t, "rice", vals, small,
+			func(w BitsWriter, v uint64) error { return WriteRice(w, v, k) },
+			func(r BitsReader) (uint64, error) { return ReadRice(r, k) },
+		)
+	}
+	if WriteRice(NewWriter(0), 1, 65) != ErrInvalidParameter {
+		t.Fatalf("k > 64 should fail")
+	}
+
+	// with k 64 the value is all remainder, any unary part is bad
+	w := NewWriter(16)
+	writeUnary(w, 1)
+	w.WriteBits(5, 64)
+	if _, err := ReadRice(w.Reader(), 64); err != ErrInvalidCode {
+		t.Fatalf("expected ErrInvalidCode for k 64 with a quotient got %v", err)
+	}
+}
+
+func TestGolomb(t *testing.T) {
+	for _, m := range []uint64{1, 2, 3, 10, 1000, 1 << 40, math.MaxUint64 / 3, math.MaxUint64} {
+		m := m
+		// the unary part is v / m, keep it sane
+		small := func(v uint64) uint64 {
+			if v/m >= 1024 {
+				return v % (m * 1024)
+			}
+			return v
+		}
+		var vals []uint64
+		for _, v := range testUint64s() {
+			if small(v) == v {
+				vals = append(vals, v)
+			}
+		}
+		checkCode(t, "golomb", vals, small,
+			func(w BitsWriter, v uint64) error { return WriteGolomb(w, v, m) },
+			func(r BitsReader) (uint64, error) { return ReadGolomb(r, m) },
+		)
+	}
+
+	// m = 3: 0 -> 0 0, 1 -> 0 10, 2 -> 0 11, 3 -> 10 0
+	w := NewWriter(0)
+	for _, v := range []uint64{0, 1, 2, 3} {
+		WriteGolomb(w, v, 3)
+	}
+	if u, _ := w.Reader().ReadBits(11); u != 0x9c {
+		t.Fatalf("golomb bits: %b", u)
+	}
+	if WriteGolomb(w, 1, 0) != ErrInvalidParameter {
+		t.Fatalf("m == 0 should fail")
+	}
+}
@@ -0,0 +1,51 @@
+package bitstream
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrShortRead there were not enough bits left for a read, the actual error is
+// a *ShortReadError, check with errors.Is(err, ErrShortRead) or errors.As
+var ErrShortRead = errors.New("bitstream: short read")
+
+// ErrBitCount more then 64 (or less then 0) bits asked for in one go
+var ErrBitCount = errors.New("bitstream: bit count must be between 0 and 64")
+
+// ShortReadError a read wanted more bits then were there
+//
+// It also matches io.ErrUnexpectedEOF (or io.EOF if no bits at all were available)
+// with errors.Is so code looking for those keeps working
+type ShortReadError struct {
+	// Requested bits asked for
+	Requested int64
+	// Available bits that were left
+	Available int64
+}
+
+// Error the error string
+func (e *ShortReadError) Error() string {
+	return fmt.Sprintf("bitstream: short read, %d bits requested %d available", e.Requested, e.Available)
+}
+
+// Is matches ErrShortRead
+func (e *ShortReadError) Is(target error) bool {
+	return target == ErrShortRead
+}
+
+// Unwrap io.EOF if nothing was available, io.ErrUnexpectedEOF otherwise
+func (e *ShortReadError) Unwrap() error {
+	if e.Available <= 0 {
+		return io.EOF
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// checkBitCount nbits in [0, 64]
+func checkBitCount(nbits int) error {
+	if nbits < 0 || nbits > 64 {
+		return ErrBitCount
+	}
+	return nil
+}
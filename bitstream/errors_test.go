@@ -0,0 +1,121 @@
+package bitstream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func checkShort(t *testing.T, err error, requested, available int64) {
+	t.Helper()
+	var se *ShortReadError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a *ShortReadError got %v", err)
+	}
+	if se.Requested != requested || se.Available != available {
+		t.Fatalf("short read got %d/%d wanted %d/%d", se.Requested, se.Available, requested, available)
+	}
+	if !errors.Is(err, ErrShortRead) {
+		t.Fatalf("%v is not ErrShortRead", err)
+	}
+}
+
+func TestShortReadError(t *testing.T) {
+	err := error(&ShortReadError{Requested: 8, Available: 3})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		t.Fatalf("partial short read should be io.ErrUnexpectedEOF")
+	}
+	err = &ShortReadError{Requested: 8}
+	if !errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("empty short read should be io.EOF")
+	}
+}
+
+func TestReaderShortRead(t *testing.T) {
+	r := NewReaderBits([]byte{0xff, 0xff, 0xff}, 20)
+	if _, err := r.ReadBits(3); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	_, err := r.ReadBytes(3)
+	checkShort(t, err, 24, 17)
+	if _, err := r.ReadBits(65); err != ErrBitCount {
+		t.Fatalf("expected ErrBitCount got %v", err)
+	}
+	if _, err := r.ReadBytes(-1); err != ErrBitCount {
+		t.Fatalf("expected ErrBitCount got %v", err)
+	}
+
+	// enough bits past the offset for the bytes even if not byte aligned
+	byts, err := r.ReadBytes(2)
+	if err != nil || !bytes.Equal(byts, []byte{0xff, 0xff}) {
+		t.Fatalf("read bytes failed: %x %v", byts, err)
+	}
+	checkShort(t, func() error { _, err := r.ReadBits(2); return err }(), 2, 1)
+
+	// the clean end is io.EOF unless strict
+	r.SkipBits(1)
+	if _, err := r.ReadBit(); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+	r.SetStrict(true)
+	_, err = r.ReadBit()
+	checkShort(t, err, 1, 0)
+}
+
+func TestBitStreamStrict(t *testing.T) {
+	b := NewBWriter(4)
+	b.WriteBits(0x3ff, 10)
+	b.SetStrict(true)
+	_, err := b.ReadBits(11)
+	checkShort(t, err, 11, 10)
+	if u, err := b.ReadBits(10); err != nil || u != 0x3ff {
+		t.Fatalf("strict short read consumed bits: %x %v", u, err)
+	}
+	_, err = b.ReadByte()
+	checkShort(t, err, 8, 0)
+}
+
+func TestBitReaderShortRead(t *testing.T) {
+	br := NewBitReader(bytes.NewReader([]byte{0xff, 0xff}))
+	if _, err := br.ReadBits(4); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	_, err := br.ReadBits(13)
+	checkShort(t, err, 13, 12)
+	if _, err := br.ReadBit(); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+
+	br = NewBitReader(bytes.NewReader([]byte{0xff, 0xff, 0xff}))
+	br.ReadBits(4)
+	_, err = br.ReadBytes(3)
+	checkShort(t, err, 24, 20)
+	if _, err := br.ReadBytes(-1); err != ErrBitCount {
+		t.Fatalf("expected ErrBitCount got %v", err)
+	}
+
+	br = NewBitReader(bytes.NewReader([]byte{0xff, 0xff, 0xff}))
+	err = br.SkipBits(30)
+	checkShort(t, err, 30, 24)
+}
+
+func TestBitReaderStrict(t *testing.T) {
+	br := NewBitReaderSize(iotest.OneByteReader(bytes.NewReader([]byte{0xab, 0xcd})), 1)
+	br.SetStrict(true)
+	if u, err := br.ReadBits(4); err != nil || u != 0xa {
+		t.Fatalf("read failed: %x %v", u, err)
+	}
+	_, err := br.ReadBits(13)
+	checkShort(t, err, 13, 12)
+	_, err = br.ReadBytes(2)
+	checkShort(t, err, 16, 12)
+
+	// nothing was lost
+	if u, err := br.ReadBits(12); err != nil || u != 0xbcd {
+		t.Fatalf("strict short read consumed bits: %x %v", u, err)
+	}
+	_, err = br.ReadBit()
+	checkShort(t, err, 1, 0)
+}
@@ -0,0 +1,534 @@
+package bitstream
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+/*
+Float codecs
+
+	XOR (Gorilla): the first value in 64 bits then the xor with the previous value as
+	    '0' (same value)
+	    '10' + the meaningful bits (fits in the last leading/trailing zero window)
+	    '11' + leading zeros (5 bits) + meaningful bit count (6 bits, 0 is 64) + the meaningful bits
+	Chimp: the first value in 64 bits then the xor with a reference value as
+	    '00' + ref index (same value)
+	    '01' + ref index + rounded leading zeros (3 bits) + center bit count (6 bits) + the center bits
+	        (lots of trailing zeros)
+	    '10' + the bits after the leading zeros (same rounded leading zeros as last time)
+	    '11' + rounded leading zeros (3 bits) + the bits after the leading zeros
+	  with a window of 1 the reference is always the previous value (Chimp), with a
+	  window of N (ChimpN) it is one of the last N values that shares the low bits and the
+	  index takes log2(N) bits
+	Decimal: values with a known number of decimal places as the signed Exp-Golomb coded
+	    delta of the scaled integers
+*/
+
+// ErrNotDecimal the value does not have the given decimal places (or is NaN/Inf/too big)
+var ErrNotDecimal = errors.New("bitstream: value is not a decimal with the given places")
+
+// XOREncoder Gorilla style XOR float encoder, the zero value is ready to use
+type XOREncoder struct {
+	prev     uint64
+	leading  uint8
+	trailing uint8
+	started  bool
+}
+
+// Reset start over, the next value is written in full
+func (e *XOREncoder) Reset() {
+	*e = XOREncoder{}
+}
+
+// Encode write v
+func (e *XOREncoder) Encode(w BitsWriter, v float64) {
+	u := math.Float64bits(v)
+	if !e.started {
+		e.started = true
+		e.prev = u
+		e.leading = ^uint8(0)
+		w.WriteBits(u, 64)
+		return
+	}
+
+	xor := u ^ e.prev
+	e.prev = u
+	if xor == 0 {
+		w.WriteBit(ZeroBit)
+		return
+	}
+	w.WriteBit(OneBit)
+
+	leading := uint8(bits.LeadingZeros64(xor))
+	trailing := uint8(bits.TrailingZeros64(xor))
+
+	// clamp number of leading zeros to avoid overflow when encoding
+	if leading >= 32 {
+		leading = 31
+	}
+
+	if e.leading != ^uint8(0) && leading >= e.leading && trailing >= e.trailing {
+		// fits in the last meaningful window
+		w.WriteBit(ZeroBit)
+		w.WriteBits(xor>>e.trailing, 64-int(e.leading)-int(e.trailing))
+		return
+	}
+	e.leading, e.trailing = leading, trailing
+
+	w.WriteBit(OneBit)
+	w.WriteBits(uint64(leading), 5)
+
+	// 64 significant bits will not fit in 6 bits, but 0 can never
+	// be a real value (xor != 0) so 0 means 64
+	sigbits := 64 - leading - trailing
+	w.WriteBits(uint64(sigbits), 6)
+	w.WriteBits(xor>>trailing, int(sigbits))
+}
+
+// XORDecoder reads what XOREncoder writes, the zero value is ready to use
+type XORDecoder struct {
+	prev     uint64
+	leading  uint8
+	trailing uint8
+	started  bool
+	window   bool
+}
+
+// Reset start over, the next value is read in full
+func (d *XORDecoder) Reset() {
+	*d = XORDecoder{}
+}
+
+// Decode read the next value
+func (d *XORDecoder) Decode(r BitsReader) (float64, error) {
+	if !d.started {
+		u, err := r.ReadBits(64)
+		if err != nil {
+			return 0, err
+		}
+		d.started = true
+		d.prev = u
+		return math.Float64frombits(u), nil
+	}
+
+	bit, err := r.ReadBit()
+	if err != nil {
+		return 0, err
+	}
+	if bit == ZeroBit {
+		return math.Float64frombits(d.prev), nil
+	}
+
+	bit, err = r.ReadBit()
+	if err != nil {
+		return 0, err
+	}
+	if bit == OneBit {
+		// new leading/trailing window
+		leading, err := r.ReadBits(5)
+		if err != nil {
+			return 0, err
+		}
+		mbits, err := r.ReadBits(6)
+		if err != nil {
+			return 0, err
+		}
+		if mbits == 0 {
+			mbits = 64
+		}
+		if leading+mbits > 64 {
+			return 0, ErrInvalidCode
+		}
+		d.leading = uint8(leading)
+		d.trailing = uint8(64 - leading - mbits)
+		d.window = true
+	} else if !d.window {
+		return 0, ErrInvalidCode
+	}
+
+	xor, err := r.ReadBits(int(64 - d.leading - d.trailing))
+	if err != nil {
+		return 0, err
+	}
+	d.prev ^= xor << d.trailing
+	return math.Float64frombits(d.prev), nil
+}
+
+// chimp leading zeros are rounded down to one of these, stored as the index
+var chimpLeading = [8]int{0, 8, 12, 16, 18, 20, 22, 24}
+
+// chimpLeadingCode the index of the rounded down leading zeros
+func chimpLeadingCode(lz int) int {
+	for c := len(chimpLeading) - 1; c > 0; c-- {
+		if lz >= chimpLeading[c] {
+			return c
+		}
+	}
+	return 0
+}
+
+// no leading zeros stored, '10' can not be used
+const chimpNoLeading = 65
+
+// largest ChimpN window
+const maxChimpWindow = 1 << 10
+
+// chimpWindow the window rounded up to a power of two (1 to maxChimpWindow) and its log2
+func chimpWindow(window int) (int, int) {
+	if window <= 1 {
+		return 1, 0
+	}
+	if window > maxChimpWindow {
+		window = maxChimpWindow
+	}
+	logN := bits.Len(uint(window - 1))
+	return 1 << uint(logN), logN
+}
+
+// ChimpEncoder Chimp (window 1) or ChimpN float encoder
+type ChimpEncoder struct {
+	ring []uint64
+	// the last value index with a given set of low bits (ChimpN only)
+	indices []int
+	n       int
+	logN    int
+	leading int
+}
+
+// NewChimpEncoder a Chimp encoder looking back over window values (rounded up to a
+// power of two, at most 1024), 1 is plain Chimp, 128 is the usual ChimpN
+func NewChimpEncoder(window int) *ChimpEncoder {
+	n, logN := chimpWindow(window)
+	e := &ChimpEncoder{ring: make([]uint64, n), logN: logN, leading: chimpNoLeading}
+	if n > 1 {
+		e.indices = make([]int, 1<<uint(e.threshold()+1))
+	}
+	return e
+}
+
+// Reset start over, the next value is written in full
+func (e *ChimpEncoder) Reset() {
+	e.n = 0
+	e.leading = chimpNoLeading
+	for i := range e.ring {
+		e.ring[i] = 0
+	}
+	for i := range e.indices {
+		e.indices[i] = 0
+	}
+}
+
+// threshold trailing zeros needed to use the '01' form
+func (e *ChimpEncoder) threshold() int {
+	return 6 + e.logN
+}
+
+// Encode write v
+func (e *ChimpEncoder) Encode(w BitsWriter, v float64) {
+	u := math.Float64bits(v)
+	size := len(e.ring)
+	if e.n == 0 {
+		w.WriteBits(u, 64)
+		e.store(u)
+		return
+	}
+
+	threshold := e.threshold()
+	ref := (e.n - 1) % size
+	xor := e.ring[ref] ^ u
+	if size > 1 {
+		// an older value with the same low bits may give more trailing zeros
+		if c := e.indices[u&(1<<uint(threshold+1)-1)]; e.n-c <= size {
+			if x := e.ring[c%size] ^ u; bits.TrailingZeros64(x) > threshold {
+				ref, xor = c%size, x
+			}
+		}
+	}
+
+	tz := bits.TrailingZeros64(xor)
+	switch {
+	case xor == 0:
+		w.WriteBits(0x0, 2)
+		w.WriteBits(uint64(ref), e.logN)
+		e.leading = chimpNoLeading
+	case tz > threshold:
+		code := chimpLeadingCode(bits.LeadingZeros64(xor))
+		center := 64 - chimpLeading[code] - tz
+		w.WriteBits(0x1, 2)
+		w.WriteBits(uint64(ref), e.logN)
+		w.WriteBits(uint64(code), 3)
+		w.WriteBits(uint64(center), 6)
+		w.WriteBits(xor>>uint(tz), center)
+		e.leading = chimpNoLeading
+	default:
+		code := chimpLeadingCode(bits.LeadingZeros64(xor))
+		lz := chimpLeading[code]
+		if lz == e.leading {
+			w.WriteBits(0x2, 2)
+		} else {
+			w.WriteBits(0x3, 2)
+			w.WriteBits(uint64(code), 3)
+			e.leading = lz
+		}
+		w.WriteBits(xor, 64-lz)
+	}
+	e.store(u)
+}
+
+func (e *ChimpEncoder) store(u uint64) {
+	e.ring[e.n%len(e.ring)] = u
+	if e.indices != nil {
+		e.indices[u&uint64(len(e.indices)-1)] = e.n
+	}
+	e.n++
+}
+
+// ChimpDecoder reads what a ChimpEncoder with the same window writes
+type ChimpDecoder struct {
+	ring    []uint64
+	n       int
+	logN    int
+	leading int
+}
+
+// NewChimpDecoder a Chimp decoder, window must match the encoder
+func NewChimpDecoder(window int) *ChimpDecoder {
+	n, logN := chimpWindow(window)
+	return &ChimpDecoder{ring: make([]uint64, n), logN: logN, leading: chimpNoLeading}
+}
+
+// Reset start over, the next value is read in full
+func (d *ChimpDecoder) Reset() {
+	d.n = 0
+	d.leading = chimpNoLeading
+	for i := range d.ring {
+		d.ring[i] = 0
+	}
+}
+
+// Decode read the next value
+func (d *ChimpDecoder) Decode(r BitsReader) (float64, error) {
+	var u uint64
+	if d.n == 0 {
+		v, err := r.ReadBits(64)
+		if err != nil {
+			return 0, err
+		}
+		d.store(v)
+		return math.Float64frombits(v), nil
+	}
+
+	flag, err := r.ReadBits(2)
+	if err != nil {
+		return 0, err
+	}
+	prev := d.ring[(d.n-1)%len(d.ring)]
+	switch flag {
+	case 0x0:
+		ref, err := r.ReadBits(d.logN)
+		if err != nil {
+			return 0, err
+		}
+		u = d.ring[ref]
+		d.leading = chimpNoLeading
+	case 0x1:
+		var hdr [3]uint64
+		for i, nbits := range []int{d.logN, 3, 6} {
+			if hdr[i], err = r.ReadBits(nbits); err != nil {
+				return 0, err
+			}
+		}
+		lz, center := chimpLeading[hdr[1]], int(hdr[2])
+		if center == 0 || lz+center > 64 {
+			return 0, ErrInvalidCode
+		}
+		xor, err := r.ReadBits(center)
+		if err != nil {
+			return 0, err
+		}
+		u = d.ring[hdr[0]] ^ xor<<uint(64-lz-center)
+		d.leading = chimpNoLeading
+	default:
+		if flag == 0x3 {
+			code, err := r.ReadBits(3)
+			if err != nil {
+				return 0, err
+			}
+			d.leading = chimpLeading[code]
+		} else if d.leading == chimpNoLeading {
+			return 0, ErrInvalidCode
+		}
+		xor, err := r.ReadBits(64 - d.leading)
+		if err != nil {
+			return 0, err
+		}
+		u = prev ^ xor
+	}
+	d.store(u)
+	return math.Float64frombits(u), nil
+}
+
+func (d *ChimpDecoder) store(u uint64) {
+	d.ring[d.n%len(d.ring)] = u
+	d.n++
+}
+
+// powers of ten that are exact (and fit an int64 scaled value)
+const maxDecimalPlaces = 18
+
+// DecimalEncoder stores values with a fixed number of decimal places as the delta of
+// the scaled integers (signed Exp-Golomb), 12.34 with 2 places is 1234
+type DecimalEncoder struct {
+	scale float64
+	prev  int64
+}
+
+// NewDecimalEncoder an encoder for values with places (0 to 18) decimal places
+func NewDecimalEncoder(places int) (*DecimalEncoder, error) {
+	scale, err := decimalScale(places)
+	if err != nil {
+		return nil, err
+	}
+	return &DecimalEncoder{scale: scale}, nil
+}
+
+func decimalScale(places int) (float64, error) {
+	if places < 0 || places > maxDecimalPlaces {
+		return 0, ErrInvalidParameter
+	}
+	return math.Pow10(places), nil
+}
+
+// Reset start over from 0
+func (e *DecimalEncoder) Reset() {
+	e.prev = 0
+}
+
+// Encode write v, ErrNotDecimal (and nothing is written) if v does not scale to an
+// integer that scales back to exactly v, -0 comes back as 0
+func (e *DecimalEncoder) Encode(w BitsWriter, v float64) error {
+	s := math.Round(v * e.scale)
+	if math.IsNaN(s) || math.Abs(s) >= 1<<63 || s/e.scale != v {
+		return ErrNotDecimal
+	}
+	n := int64(s)
+	WriteSignedExpGolomb(w, n-e.prev)
+	e.prev = n
+	return nil
+}
+
+// DecimalDecoder reads what a DecimalEncoder with the same places writes
+type DecimalDecoder struct {
+	scale float64
+	prev  int64
+}
+
+// NewDecimalDecoder a decoder for values with places decimal places
+func NewDecimalDecoder(places int) (*DecimalDecoder, error) {
+	scale, err := decimalScale(places)
+	if err != nil {
+		return nil, err
+	}
+	return &DecimalDecoder{scale: scale}, nil
+}
+
+// Reset start over from 0
+func (d *DecimalDecoder) Reset() {
+	d.prev = 0
+}
+
+// Decode read the next value
+func (d *DecimalDecoder) Decode(r BitsReader) (float64, error) {
+	delta, err := ReadSignedExpGolomb(r)
+	if err != nil {
+		return 0, err
+	}
+	d.prev += delta
+	return float64(d.prev) / d.scale, nil
+}
+
+// Float32ToFloat16 convert to IEEE 754 half precision, rounding to nearest even, too
+// big is ±Inf, too small is ±0 and NaNs stay (quiet) NaNs
+func Float32ToFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xff
+	mant := b & 0x7fffff
+
+	if exp == 0xff {
+		if mant == 0 {
+			return sign | 0x7c00
+		}
+		return sign | 0x7e00 | uint16(mant>>13)
+	}
+
+	e := exp - 127 + 15
+	switch {
+	case e >= 0x1f:
+		return sign | 0x7c00
+	case e <= 0:
+		// a half subnormal (or zero)
+		if e < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint(14 - e)
+		h := mant >> shift
+		rem := mant & (1<<shift - 1)
+		half := uint32(1) << (shift - 1)
+		if rem > half || (rem == half && h&1 == 1) {
+			h++
+		}
+		return sign | uint16(h)
+	}
+
+	// rounding up can carry into the exponent, all the way to Inf
+	h := uint32(e)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && h&1 == 1) {
+		h++
+	}
+	return sign | uint16(h)
+}
+
+// Float16ToFloat32 convert from IEEE 754 half precision (exact)
+func Float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// subnormal, normalise it
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3ff)<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// Float32ToBFloat16 convert to bfloat16 (the top half of a float32), rounding to
+// nearest even, NaNs stay (quiet) NaNs
+func Float32ToBFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	if b&0x7fffffff > 0x7f800000 {
+		return uint16(b>>16) | 0x40
+	}
+	b += 0x7fff + (b>>16)&1
+	return uint16(b >> 16)
+}
+
+// BFloat16ToFloat32 convert from bfloat16 (exact)
+func BFloat16ToFloat32(h uint16) float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
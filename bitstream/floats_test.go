@@ -0,0 +1,231 @@
+package bitstream
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var testFloats = []float64{
+	0, math.Copysign(0, -1), 1, 1, 1.5, -1.5, 123123.123, 2, 2.0000001,
+	math.Inf(1), math.Inf(-1), math.NaN(), math.MaxFloat64, -math.MaxFloat64,
+	math.SmallestNonzeroFloat64, 4.9e-320, 2.2250738585072014e-308, 1, 1, 3.14159,
+}
+
+func floatSeries() []float64 {
+	vals := append([]float64{}, testFloats...)
+	rnd := rand.New(rand.NewSource(42))
+	v := 100.0
+	for i := 0; i < 500; i++ {
+		v += math.Round(rnd.NormFloat64()*100) / 100
+		vals = append(vals, v)
+	}
+	return append(vals, testFloats...)
+}
+
+func sameFloat(a, b float64) bool {
+	return math.Float64bits(a) == math.Float64bits(b)
+}
+
+func TestXORFloats(t *testing.T) {
+	vals := floatSeries()
+	w := NewWriter(0)
+	var enc XOREncoder
+	for _, v := range vals {
+		enc.Encode(w, v)
+	}
+
+	var dec XORDecoder
+	r := w.Reader()
+	for i, want := range vals {
+		v, err := dec.Decode(r)
+		if err != nil || !sameFloat(v, want) {
+			t.Fatalf("value %d: got %v (%v) wanted %v", i, v, err, want)
+		}
+	}
+	if r.Remaining() != 0 {
+		t.Fatalf("%d bits left over", r.Remaining())
+	}
+
+	enc.Reset()
+	w.Reset()
+	enc.Encode(w, 1)
+	if w.BitLen() != 64 {
+		t.Fatalf("reset encoder should write the first value in full")
+	}
+}
+
+func TestChimpFloats(t *testing.T) {
+	vals := floatSeries()
+	for _, window := range []int{1, 2, 100, 128} {
+		w := NewWriter(0)
+		enc := NewChimpEncoder(window)
+		for _, v := range vals {
+			enc.Encode(w, v)
+		}
+
+		dec := NewChimpDecoder(window)
+		r := w.Reader()
+		for i, want := range vals {
+			v, err := dec.Decode(r)
+			if err != nil || !sameFloat(v, want) {
+				t.Fatalf("window %d value %d: got %v (%v) wanted %v", window, i, v, err, want)
+			}
+		}
+		if r.Remaining() != 0 {
+			t.Fatalf("window %d: %d bits left over", window, r.Remaining())
+		}
+
+		// and again after a reset
+		enc.Reset()
+		dec.Reset()
+		w.Reset()
+		for _, v := range testFloats {
+			enc.Encode(w, v)
+		}
+		r = w.Reader()
+		for i, want := range testFloats {
+			if v, err := dec.Decode(r); err != nil || !sameFloat(v, want) {
+				t.Fatalf("window %d value %d after reset: got %v (%v) wanted %v", window, i, v, err, want)
+			}
+		}
+	}
+}
+
+func TestChimpWindow(t *testing.T) {
+	// a repeating pattern is all reference hits with a window
+	vals := make([]float64, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		vals = append(vals, float64(i%50)*1.1)
+	}
+	size := func(window int) int64 {
+		w := NewWriter(0)
+		enc := NewChimpEncoder(window)
+		for _, v := range vals {
+			enc.Encode(w, v)
+		}
+		return w.BitLen()
+	}
+	if plain, n := size(1), size(64); n >= plain {
+		t.Fatalf("window 64 (%d bits) should beat plain Chimp (%d bits)", n, plain)
+	}
+}
+
+func TestDecimalFloats(t *testing.T) {
+	vals := []float64{0, 1.23, -1.23, 100, 99.99, 0.01, -0.01, 12345678901.23, -12345678901.23}
+	w := NewWriter(0)
+	enc, err := NewDecimalEncoder(2)
+	if err != nil {
+		t.Fatalf("new encoder failed: %v", err)
+	}
+	for _, v := range vals {
+		if err := enc.Encode(w, v); err != nil {
+			t.Fatalf("encode %v failed: %v", v, err)
+		}
+	}
+	dec, _ := NewDecimalDecoder(2)
+	r := w.Reader()
+	for i, want := range vals {
+		if v, err := dec.Decode(r); err != nil || v != want {
+			t.Fatalf("value %d: got %v (%v) wanted %v", i, v, err, want)
+		}
+	}
+
+	before := w.BitLen()
+	for _, v := range []float64{1.234, math.NaN(), math.Inf(1), math.Inf(-1), math.SmallestNonzeroFloat64, 1e300} {
+		if err := enc.Encode(w, v); err != ErrNotDecimal {
+			t.Fatalf("%v: expected ErrNotDecimal got %v", v, err)
+		}
+	}
+	if w.BitLen() != before {
+		t.Fatalf("failed encodes wrote bits")
+	}
+
+	if _, err := NewDecimalEncoder(19); err != ErrInvalidParameter {
+		t.Fatalf("expected ErrInvalidParameter got %v", err)
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	for _, c := range []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.333251953125, 0x3555},
+		{65504, 0x7bff},
+		{65519, 0x7bff},
+		{65520, 0x7c00}, // rounds up to Inf
+		{1e10, 0x7c00},
+		{float32(math.Inf(-1)), 0xfc00},
+		{6.103515625e-05, 0x0400},        // smallest normal
+		{6.097555160522461e-05, 0x03ff},  // largest subnormal
+		{5.960464477539063e-08, 0x0001},  // smallest subnormal
+		{2.9802322387695312e-08, 0x0000}, // half way to the smallest ties to even
+		{2.98023259e-08, 0x0001},
+		{1e-10, 0x0000},
+		{1.00048828125, 0x3c00}, // tie, stays even
+		{1.00146484375, 0x3c02}, // tie, rounds up to even
+	} {
+		if h := Float32ToFloat16(c.f); h != c.h {
+			t.Fatalf("%v: got %04x wanted %04x", c.f, h, c.h)
+		}
+	}
+
+	// every half goes there and back
+	for i := 0; i < 1<<16; i++ {
+		h := uint16(i)
+		f := Float16ToFloat32(h)
+		if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+			if !math.IsNaN(float64(f)) || !math.IsNaN(float64(Float16ToFloat32(Float32ToFloat16(f)))) {
+				t.Fatalf("%04x: NaN lost", h)
+			}
+			continue
+		}
+		if back := Float32ToFloat16(f); back != h {
+			t.Fatalf("%04x -> %v -> %04x", h, f, back)
+		}
+	}
+	if h := Float32ToFloat16(float32(math.NaN())); h&0x7c00 != 0x7c00 || h&0x3ff == 0 {
+		t.Fatalf("NaN became %04x", h)
+	}
+}
+
+func TestBFloat16(t *testing.T) {
+	for _, c := range []struct {
+		f float32
+		h uint16
+	}{
+		{1, 0x3f80},
+		{-2, 0xc000},
+		{3.140625, 0x4049},
+		{1.00390625, 0x3f80}, // tie, stays even
+		{1.01171875, 0x3f82}, // tie, rounds up to even
+		{math.MaxFloat32, 0x7f80},
+		{float32(math.Inf(1)), 0x7f80},
+		{1e-40, 0x0001}, // subnormal
+	} {
+		if h := Float32ToBFloat16(c.f); h != c.h {
+			t.Fatalf("%v: got %04x wanted %04x", c.f, h, c.h)
+		}
+	}
+	for i := 0; i < 1<<16; i++ {
+		h := uint16(i)
+		f := BFloat16ToFloat32(h)
+		if math.IsNaN(float64(f)) {
+			if !math.IsNaN(float64(BFloat16ToFloat32(Float32ToBFloat16(f)))) {
+				t.Fatalf("%04x: NaN lost", h)
+			}
+			continue
+		}
+		if back := Float32ToBFloat16(f); back != h {
+			t.Fatalf("%04x -> %v -> %04x", h, f, back)
+		}
+	}
+	if h := Float32ToBFloat16(math.Float32frombits(0x7f800001)); h&0x7f != 0x40 || h&0x7f80 != 0x7f80 {
+		t.Fatalf("signalling NaN became %04x", h)
+	}
+}
@@ -0,0 +1,138 @@
+package bitstream
+
+import (
+	"bytes"
+	"testing"
+)
+
+// the fuzz targets only make sure arbitrary input never panics (or runs away),
+// run them with go test -fuzz=FuzzXxx
+
+func FuzzReader(f *testing.F) {
+	f.Add([]byte{0xde, 0xad, 0xbe, 0xef}, []byte{1, 7, 64, 13, 0})
+	f.Add([]byte{}, []byte{65})
+	f.Fuzz(func(t *testing.T, data []byte, ops []byte) {
+		for _, strict := range []bool{false, true} {
+			for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+				r := NewReaderBits(data, int64(len(data))*8-int64(len(ops)%8))
+				r.SetOrder(order)
+				r.SetStrict(strict)
+				br := NewBitReaderSize(bytes.NewReader(data), 1+len(ops)%5)
+				br.SetOrder(order)
+				br.SetStrict(strict)
+				for _, op := range ops {
+					n := int(op % 72)
+					switch op >> 6 {
+					case 0:
+						r.ReadBits(n)
+						br.ReadBits(n)
+					case 1:
+						r.PeekBits(n)
+						br.PeekBits(n)
+					case 2:
+						r.ReadBytes(n % 9)
+						br.ReadBytes(n % 9)
+					default:
+						r.SkipBits(int64(n))
+						br.SkipBits(int64(n))
+					}
+					if r.Position() > r.BitLen() {
+						t.Fatalf("read past the end %d > %d", r.Position(), r.BitLen())
+					}
+				}
+			}
+		}
+	})
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	b := NewBWriter(8)
+	writeTestBits(b)
+	enc, _ := b.MarshalBinary()
+	f.Add(enc)
+	f.Add([]byte{3, 0xff, 0x80})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var b BitStream
+		if err := b.UnmarshalBinary(data); err != nil {
+			return
+		}
+		for {
+			if _, err := b.ReadBits(13); err != nil {
+				break
+			}
+		}
+		if _, err := b.MarshalBinary(); err != nil {
+			t.Fatalf("marshal of an unmarshalled stream failed: %v", err)
+		}
+	})
+}
+
+// 64 zeros, a one, then 5 as the 64 bit suffix, an Exp-Golomb code past MaxUint64
+var expGolombTooBig = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x80, 0, 0, 0, 0, 0, 0, 0x02, 0x80}
+
+func FuzzCodes(f *testing.F) {
+	f.Add(expGolombTooBig, uint8(0))
+	f.Add([]byte{0x01, 0x23, 0x45, 0x67}, uint8(3))
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, uint8(0))
+	f.Add([]byte{0x80, 0, 0, 0, 0, 0, 0, 0x01, 0x40}, uint8(64))
+	f.Fuzz(func(t *testing.T, data []byte, k uint8) {
+		readers := []func(r *Reader) error{
+			func(r *Reader) error { _, err := ReadExpGolomb(r); return err },
+			func(r *Reader) error { _, err := ReadSignedExpGolomb(r); return err },
+			func(r *Reader) error { _, err := ReadEliasGamma(r); return err },
+			func(r *Reader) error { _, err := ReadEliasDelta(r); return err },
+			func(r *Reader) error { _, err := ReadRice(r, uint(k%70)); return err },
+			func(r *Reader) error { _, err := ReadGolomb(r, uint64(k)); return err },
+		}
+		for _, read := range readers {
+			r := NewReader(data)
+			for read(r) == nil && r.Remaining() > 0 {
+			}
+		}
+	})
+}
+
+func FuzzUnpack(f *testing.F) {
+	w := NewWriter(64)
+	PackUint64s(w, []uint64{1, 5, 9, 1 << 40, 3, 3, 3})
+	f.Add(w.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		UnpackUint64s(NewReader(data))
+
+		p, err := OpenPacked(NewReader(data))
+		if err != nil {
+			return
+		}
+		for i := 0; i < p.Len() && i < 1024; i++ {
+			if _, err := p.Get(i); err != nil {
+				t.Fatalf("get %d of an opened block failed: %v", i, err)
+			}
+		}
+	})
+}
+
+func FuzzFloats(f *testing.F) {
+	w := NewWriter(64)
+	enc := NewChimpEncoder(128)
+	for _, v := range testFloats {
+		enc.Encode(w, v)
+	}
+	f.Add(w.Bytes(), uint8(128))
+	f.Add([]byte{0x3f, 0xf0, 0, 0, 0, 0, 0, 0, 0xc0}, uint8(1))
+	f.Fuzz(func(t *testing.T, data []byte, window uint8) {
+		var xd XORDecoder
+		r := NewReader(data)
+		for _, err := xd.Decode(r); err == nil; _, err = xd.Decode(r) {
+		}
+
+		cd := NewChimpDecoder(int(window))
+		r = NewReader(data)
+		for _, err := cd.Decode(r); err == nil; _, err = cd.Decode(r) {
+		}
+
+		dd, _ := NewDecimalDecoder(int(window % 19))
+		r = NewReader(data)
+		for _, err := dd.Decode(r); err == nil; _, err = dd.Decode(r) {
+		}
+	})
+}
@@ -0,0 +1,385 @@
+/**
+huffman
+
+Canonical Huffman codes on top of the bitstream package
+
+A Table is built from symbol frequencies (symbols are just the index into the
+frequency slice), only the code lengths matter for a canonical code so only they
+need to be stored (WriteLengths/ReadTable).  Decoding uses a lookup table on the
+next few bits when the reader can peek (bitstream.Reader, BitStream and BitReader all can)
+falling back to walking the canonical code a bit at a time.
+
+	t, err := huffman.New(freqs)
+	w := bitstream.NewWriter(1024)
+	t.WriteLengths(w)
+	t.Encode(w, syms)
+
+	r := w.Reader()
+	t, err = huffman.ReadTable(r)
+	syms, err = t.Decode(r, len(syms))
+*/
+
+package huffman
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+// MaxCodeLen the longest code that will be made
+const MaxCodeLen = 24
+
+// MaxSymbols the largest alphabet supported
+const MaxSymbols = 1 << 20
+
+// bits looked up at once when decoding
+const lookupBits = 10
+
+// ErrNoSymbols there were no symbols with a frequency
+var ErrNoSymbols = errors.New("huffman: no symbols to code")
+
+// ErrTooManySymbols the alphabet is larger then MaxSymbols
+var ErrTooManySymbols = errors.New("huffman: too many symbols")
+
+// ErrUnknownSymbol the symbol has no code
+var ErrUnknownSymbol = errors.New("huffman: symbol has no code")
+
+// ErrInvalidLengths the code lengths do not make a valid prefix code
+var ErrInvalidLengths = errors.New("huffman: invalid code lengths")
+
+// ErrInvalidCode the bits read are not a code in the table
+var ErrInvalidCode = errors.New("huffman: invalid code")
+
+// Table a canonical Huffman code
+type Table struct {
+	lengths []uint8
+	codes   []uint32
+
+	// canonical decoding, per code length: the first code, how many codes
+	// and where the symbols start in sorted
+	first  [MaxCodeLen + 1]uint32
+	count  [MaxCodeLen + 1]uint32
+	offset [MaxCodeLen + 1]uint32
+	sorted []int
+	maxLen int
+
+	// lookup table on the next lookupBits bits: symbol << 8 | length, 0 if the code is longer
+	lookup []uint32
+}
+
+type node struct {
+	freq   uint64
+	sym    int
+	left   *node
+	right  *node
+	height int
+}
+
+type nodeHeap []*node
+
+func (h nodeHeap) Len() int { return len(h) }
+func (h nodeHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].height < h[j].height
+	}
+	return h[i].freq < h[j].freq
+}
+func (h nodeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nodeHeap) Push(x interface{}) { *h = append(*h, x.(*node)) }
+func (h *nodeHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// New build a table from symbol frequencies, symbols with a zero frequency get no code
+func New(freqs []uint64) (*Table, error) {
+	if len(freqs) > MaxSymbols {
+		return nil, ErrTooManySymbols
+	}
+
+	f := make([]uint64, len(freqs))
+	copy(f, freqs)
+	for {
+		lengths, err := buildLengths(f)
+		if err != nil {
+			return nil, err
+		}
+		max := uint8(0)
+		for _, l := range lengths {
+			if l > max {
+				max = l
+			}
+		}
+		if max <= MaxCodeLen {
+			return NewFromLengths(lengths)
+		}
+		// too deep, flatten the distribution and try again
+		for i, v := range f {
+			if v > 0 {
+				// halve rounding up, (v+1)/2 wraps for MaxUint64
+				f[i] = v/2 + v&1
+			}
+		}
+	}
+}
+
+// buildLengths the plain Huffman code lengths
+func buildLengths(freqs []uint64) ([]uint8, error) {
+	h := make(nodeHeap, 0, len(freqs))
+	for sym, f := range freqs {
+		if f > 0 {
+			h = append(h, &node{freq: f, sym: sym})
+		}
+	}
+	lengths := make([]uint8, len(freqs))
+	switch len(h) {
+	case 0:
+		return nil, ErrNoSymbols
+	case 1:
+		// a lone symbol still needs a bit
+		lengths[h[0].sym] = 1
+		return lengths, nil
+	}
+
+	heap.Init(&h)
+	for h.Len() > 1 {
+		a := heap.Pop(&h).(*node)
+		b := heap.Pop(&h).(*node)
+		height := a.height
+		if b.height > height {
+			height = b.height
+		}
+		freq := a.freq + b.freq
+		if freq < a.freq {
+			// huge frequencies, saturate (the flattening in New brings them down)
+			freq = math.MaxUint64
+		}
+		heap.Push(&h, &node{freq: freq, left: a, right: b, height: height + 1})
+	}
+
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if n.left == nil {
+			if depth > 255 {
+				depth = 255
+			}
+			lengths[n.sym] = uint8(depth)
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(h[0], 0)
+	return lengths, nil
+}
+
+// NewFromLengths build a table from code lengths (0 for no code)
+func NewFromLengths(lengths []uint8) (*Table, error) {
+	if len(lengths) > MaxSymbols {
+		return nil, ErrTooManySymbols
+	}
+
+	t := &Table{
+		lengths: make([]uint8, len(lengths)),
+		codes:   make([]uint32, len(lengths)),
+	}
+	copy(t.lengths, lengths)
+
+	for sym, l := range lengths {
+		if l > MaxCodeLen {
+			return nil, ErrInvalidLengths
+		}
+		if l > 0 {
+			t.sorted = append(t.sorted, sym)
+			t.count[l]++
+			if int(l) > t.maxLen {
+				t.maxLen = int(l)
+			}
+		}
+	}
+	if len(t.sorted) == 0 {
+		return nil, ErrNoSymbols
+	}
+	sort.SliceStable(t.sorted, func(i, j int) bool {
+		return lengths[t.sorted[i]] < lengths[t.sorted[j]]
+	})
+
+	// canonical codes, and make sure they are not over subscribed
+	code := uint32(0)
+	offset := uint32(0)
+	for l := 1; l <= MaxCodeLen; l++ {
+		code <<= 1
+		t.first[l] = code
+		t.offset[l] = offset
+		code += t.count[l]
+		offset += t.count[l]
+		if code > 1<<uint(l) {
+			return nil, ErrInvalidLengths
+		}
+	}
+	for i, sym := range t.sorted {
+		l := lengths[sym]
+		t.codes[sym] = t.first[l] + uint32(i) - t.offset[l]
+	}
+
+	t.lookup = make([]uint32, 1<<lookupBits)
+	for sym, l := range lengths {
+		if l == 0 || l > lookupBits {
+			continue
+		}
+		shift := uint(lookupBits - l)
+		start := t.codes[sym] << shift
+		for i := uint32(0); i < 1<<shift; i++ {
+			t.lookup[start+i] = uint32(sym)<<8 | uint32(l)
+		}
+	}
+	return t, nil
+}
+
+// Lengths the code length of each symbol
+func (t *Table) Lengths() []uint8 {
+	return t.lengths
+}
+
+// Code the code (and its length) for a symbol
+func (t *Table) Code(sym int) (uint32, uint8, error) {
+	if sym < 0 || sym >= len(t.lengths) || t.lengths[sym] == 0 {
+		return 0, 0, ErrUnknownSymbol
+	}
+	return t.codes[sym], t.lengths[sym], nil
+}
+
+// WriteLengths write the code lengths, the symbol count as an Exp-Golomb code
+// then each length as a signed Exp-Golomb delta from the one before it
+func (t *Table) WriteLengths(w bitstream.BitsWriter) {
+	bitstream.WriteExpGolomb(w, uint64(len(t.lengths)))
+	last := int64(0)
+	for _, l := range t.lengths {
+		bitstream.WriteSignedExpGolomb(w, int64(l)-last)
+		last = int64(l)
+	}
+}
+
+// ReadTable read a table written by WriteLengths
+func ReadTable(r bitstream.BitsReader) (*Table, error) {
+	n, err := bitstream.ReadExpGolomb(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxSymbols {
+		return nil, ErrTooManySymbols
+	}
+	lengths := make([]uint8, n)
+	last := int64(0)
+	for i := range lengths {
+		d, err := bitstream.ReadSignedExpGolomb(r)
+		if err != nil {
+			return nil, err
+		}
+		last += d
+		if last < 0 || last > MaxCodeLen {
+			return nil, ErrInvalidLengths
+		}
+		lengths[i] = uint8(last)
+	}
+	return NewFromLengths(lengths)
+}
+
+// orderer readers/writers that know their bit order
+type orderer interface {
+	Order() bitstream.BitOrder
+}
+
+func isLSB(x interface{}) bool {
+	o, ok := x.(orderer)
+	return ok && o.Order() == bitstream.LSBFirst
+}
+
+// reverse the low n bits of u
+func reverse(u uint32, n uint8) uint32 {
+	return bits.Reverse32(u) >> (32 - n)
+}
+
+// EncodeSymbol write the code for one symbol, codes always go into the stream
+// first bit of the code first (for LSBFirst streams that means reversed, like DEFLATE)
+func (t *Table) EncodeSymbol(w bitstream.BitsWriter, sym int) error {
+	code, l, err := t.Code(sym)
+	if err != nil {
+		return err
+	}
+	if isLSB(w) {
+		code = reverse(code, l)
+	}
+	w.WriteBits(uint64(code), int(l))
+	return nil
+}
+
+// Encode write the codes for all the symbols
+func (t *Table) Encode(w bitstream.BitsWriter, syms []int) error {
+	for _, sym := range syms {
+		if err := t.EncodeSymbol(w, sym); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// peeker readers that can look ahead get the lookup table
+type peeker interface {
+	PeekBits(nbits int) (uint64, error)
+	SkipBits(nbits int64) error
+}
+
+// DecodeSymbol read one symbol
+func (t *Table) DecodeSymbol(r bitstream.BitsReader) (int, error) {
+	if p, ok := r.(peeker); ok {
+		if u, err := p.PeekBits(lookupBits); err == nil {
+			if isLSB(r) {
+				u = uint64(reverse(uint32(u), lookupBits))
+			}
+			if e := t.lookup[u]; e != 0 {
+				if err := p.SkipBits(int64(e & 0xff)); err != nil {
+					return 0, err
+				}
+				return int(e >> 8), nil
+			}
+		}
+	}
+
+	// walk the canonical code
+	code := uint32(0)
+	for l := 1; l <= t.maxLen; l++ {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		code <<= 1
+		if bit {
+			code |= 1
+		}
+		if idx := code - t.first[l]; code >= t.first[l] && idx < t.count[l] {
+			return t.sorted[t.offset[l]+idx], nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// Decode read n symbols
+func (t *Table) Decode(r bitstream.BitsReader, n int) ([]int, error) {
+	syms := make([]int, n)
+	for i := range syms {
+		sym, err := t.DecodeSymbol(r)
+		if err != nil {
+			return nil, err
+		}
+		syms[i] = sym
+	}
+	return syms, nil
+}
@@ -0,0 +1,184 @@
+package huffman
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+func testSymbols(n, alphabet int, seed int64) ([]int, []uint64) {
+	rnd := rand.New(rand.NewSource(seed))
+	syms := make([]int, n)
+	freqs := make([]uint64, alphabet)
+	for i := range syms {
+		// roughly zipf-ish
+		s := int(rnd.ExpFloat64() * float64(alphabet) / 8)
+		if s >= alphabet {
+			s = alphabet - 1
+		}
+		syms[i] = s
+		freqs[s]++
+	}
+	return syms, freqs
+}
+
+func TestHuffmanRoundTrip(t *testing.T) {
+	for _, alphabet := range []int{1, 2, 3, 17, 256, 5000} {
+		syms, freqs := testSymbols(20000, alphabet, int64(alphabet))
+		tbl, err := New(freqs)
+		if err != nil {
+			t.Fatalf("new failed: %v", err)
+		}
+
+		for _, o := range []bitstream.BitOrder{bitstream.MSBFirst, bitstream.LSBFirst} {
+			w := bitstream.NewWriter(0)
+			w.SetOrder(o)
+			tbl.WriteLengths(w)
+			if err := tbl.Encode(w, syms); err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+
+			// the table driven path
+			r := w.Reader()
+			dec, err := ReadTable(r)
+			if err != nil {
+				t.Fatalf("read table failed: %v", err)
+			}
+			if !bytes.Equal(dec.Lengths(), tbl.Lengths()) {
+				t.Fatalf("lengths differ after read")
+			}
+			got, err := dec.Decode(r, len(syms))
+			if err != nil {
+				t.Fatalf("%d %s: decode failed: %v", alphabet, o, err)
+			}
+			for i := range syms {
+				if got[i] != syms[i] {
+					t.Fatalf("%d %s: symbol %d got %d wanted %d", alphabet, o, i, got[i], syms[i])
+				}
+			}
+
+			// and a bit at a time (no peeking)
+			r = w.Reader()
+			if _, err := ReadTable(r); err != nil {
+				t.Fatalf("read table failed: %v", err)
+			}
+			for i := range syms {
+				sym, err := dec.DecodeSymbol(struct{ bitstream.BitsReader }{r})
+				if err != nil || sym != syms[i] {
+					t.Fatalf("%d %s: slow symbol %d got %d (%v) wanted %d", alphabet, o, i, sym, err, syms[i])
+				}
+			}
+		}
+	}
+}
+
+func TestHuffmanKnown(t *testing.T) {
+	// a: 1 bit, b: 2 bits, c and d: 3 bits
+	tbl, err := New([]uint64{8, 4, 2, 1})
+	if err != nil {
+		t.Fatalf("new failed: %v", err)
+	}
+	want := []uint8{1, 2, 3, 3}
+	if !bytes.Equal(tbl.Lengths(), want) {
+		t.Fatalf("lengths %v != %v", tbl.Lengths(), want)
+	}
+	for sym, code := range []uint32{0, 2, 6, 7} {
+		c, _, err := tbl.Code(sym)
+		if err != nil || c != code {
+			t.Fatalf("code %d got %b wanted %b", sym, c, code)
+		}
+	}
+}
+
+func TestHuffmanLongCodes(t *testing.T) {
+	// fibonacci frequencies give the deepest possible tree
+	freqs := make([]uint64, 60)
+	a, b := uint64(1), uint64(1)
+	for i := range freqs {
+		freqs[i] = a
+		a, b = b, a+b
+	}
+	tbl, err := New(freqs)
+	if err != nil {
+		t.Fatalf("new failed: %v", err)
+	}
+	for sym, l := range tbl.Lengths() {
+		if l == 0 || l > MaxCodeLen {
+			t.Fatalf("symbol %d has length %d", sym, l)
+		}
+	}
+}
+
+func TestHuffmanExtremeFrequencies(t *testing.T) {
+	fib := make([]uint64, 31)
+	a, b := uint64(1), uint64(1)
+	for i := 0; i < 30; i++ {
+		fib[i] = a
+		a, b = b, a+b
+	}
+	fib[30] = math.MaxUint64
+
+	for _, freqs := range [][]uint64{
+		fib,
+		{math.MaxUint64, math.MaxUint64, math.MaxUint64, 1},
+		{math.MaxUint64, 0, math.MaxUint64 - 1, 3, math.MaxUint64 / 2},
+	} {
+		tbl, err := New(freqs)
+		if err != nil {
+			t.Fatalf("new failed: %v", err)
+		}
+		var syms []int
+		for sym, f := range freqs {
+			if f == 0 {
+				continue
+			}
+			if _, l, err := tbl.Code(sym); err != nil || l > MaxCodeLen {
+				t.Fatalf("symbol %d: length %d %v", sym, l, err)
+			}
+			syms = append(syms, sym)
+		}
+		w := bitstream.NewWriter(0)
+		tbl.Encode(w, syms)
+		got, err := tbl.Decode(w.Reader(), len(syms))
+		if err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		for i := range syms {
+			if got[i] != syms[i] {
+				t.Fatalf("symbol %d got %d wanted %d", i, got[i], syms[i])
+			}
+		}
+	}
+}
+
+func TestHuffmanErrors(t *testing.T) {
+	if _, err := New(make([]uint64, 4)); err != ErrNoSymbols {
+		t.Fatalf("expected ErrNoSymbols got %v", err)
+	}
+	if _, err := NewFromLengths([]uint8{1, 1, 1}); err != ErrInvalidLengths {
+		t.Fatalf("expected ErrInvalidLengths got %v", err)
+	}
+	tbl, _ := New([]uint64{1, 0, 1})
+	if err := tbl.EncodeSymbol(bitstream.NewWriter(0), 1); err != ErrUnknownSymbol {
+		t.Fatalf("expected ErrUnknownSymbol got %v", err)
+	}
+}
+
+func FuzzReadTable(f *testing.F) {
+	tab, _ := New([]uint64{5, 1, 0, 9, 3})
+	w := bitstream.NewWriter(16)
+	tab.WriteLengths(w)
+	tab.Encode(w, []int{0, 1, 3, 4, 3})
+	f.Add(w.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bitstream.NewReader(data)
+		tab, err := ReadTable(r)
+		if err != nil {
+			return
+		}
+		tab.Decode(r, 64)
+	})
+}
@@ -0,0 +1,69 @@
+package bitstream
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLSBFirstKnown(t *testing.T) {
+	w := NewWriter(0)
+	w.SetOrder(LSBFirst)
+	w.WriteBit(OneBit)
+	w.WriteBits(1, 2)
+	w.WriteBits(5, 3)
+	w.WriteBits(0x1ff, 9)
+	// 0b11 101 01 1 and then 7 more ones
+	want := []byte{0xeb, 0x7f}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Fatalf("LSBFirst bytes %x != %x", w.Bytes(), want)
+	}
+
+	r := NewReader(want)
+	r.SetOrder(LSBFirst)
+	if b, _ := r.ReadBit(); !b {
+		t.Fatalf("first bit should be one")
+	}
+	if u, _ := r.ReadBits(2); u != 1 {
+		t.Fatalf("got %d wanted 1", u)
+	}
+	if u, _ := r.ReadBits(3); u != 5 {
+		t.Fatalf("got %d wanted 5", u)
+	}
+	if u, _ := r.PeekBits(9); u != 0x1ff {
+		t.Fatalf("got %x wanted 0x1ff", u)
+	}
+}
+
+func TestBitOrderRoundTrip(t *testing.T) {
+	for _, o := range []BitOrder{MSBFirst, LSBFirst} {
+		w := NewWriter(0)
+		w.SetOrder(o)
+		writeTestBits(w)
+		w.WriteByte(0xa5)
+
+		buf := new(bytes.Buffer)
+		bw := NewBitWriterSize(buf, 2)
+		bw.SetOrder(o)
+		writeTestBits(bw)
+		bw.WriteByte(0xa5)
+		bw.Flush()
+
+		if !bytes.Equal(buf.Bytes(), w.Bytes()[:(w.validBits()+7)/8]) {
+			t.Fatalf("%s: BitWriter and Writer differ %x != %x", o, buf.Bytes(), w.Bytes())
+		}
+
+		bs := NewBWriter(0)
+		bs.SetOrder(o)
+		writeTestBits(bs)
+		bs.WriteByte(0xa5)
+
+		br := NewBitReader(bytes.NewReader(buf.Bytes()))
+		br.SetOrder(o)
+		for _, r := range []seekReader{w.Reader(), bs, br} {
+			readTestBits(t, r)
+			if u, err := r.ReadBits(8); err != nil || u != 0xa5 {
+				t.Fatalf("%s: last byte %x (%v)", o, u, err)
+			}
+		}
+	}
+}
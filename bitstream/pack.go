@@ -0,0 +1,375 @@
+package bitstream
+
+import (
+	"errors"
+	"math/bits"
+)
+
+/*
+Bit packing of uint64 slices
+
+The values are cut up into blocks of PackBlockSize and each block is packed with
+whichever of these is smallest
+
+	FOR (frame of reference): the block min then each value - min in the fewest bits
+	Delta: the first value then the zig-zag encoded deltas in the fewest bits (sorted lists, time stamps)
+	PFOR: like FOR but with a smaller width, the few values that do not fit are exceptions
+	    stored as (index, high bits) pairs
+
+The format is
+
+	count    Exp-Golomb (number of values)
+	blocks   ...
+
+and each block
+
+	mode     2 bits
+	n - 1    7 bits
+	width    7 bits
+	base     Exp-Golomb (the min, or the first value for Delta)
+	PFOR only:
+	    exceptions  Exp-Golomb count
+	    ex width    7 bits
+	    [index (7 bits) + high bits (ex width)] ...
+	values   n (n - 1 for Delta) * width bits
+
+As the values in a block are fixed width, Packed can get to the i-th value without
+decoding the ones before it (other then in a Delta block, where the block is summed
+up to i, so at most PackBlockSize values)
+*/
+
+// PackBlockSize values per packed block
+const PackBlockSize = 128
+
+const (
+	packFOR = iota
+	packDelta
+	packPFOR
+)
+
+// ErrPackedIndex index out of range for a packed block
+var ErrPackedIndex = errors.New("bitstream: packed index out of range")
+
+// ErrBadPacked the packed data is not valid
+var ErrBadPacked = errors.New("bitstream: invalid packed data")
+
+// expGolombLen bits for an Exp-Golomb code of v
+func expGolombLen(v uint64) int {
+	if v == ^uint64(0) {
+		return 129
+	}
+	return 2*bits.Len64(v+1) - 1
+}
+
+// packPlan how a block will be packed
+type packPlan struct {
+	mode    int
+	width   int
+	base    uint64
+	exWidth int
+	cost    int
+}
+
+func planBlock(vals []uint64) packPlan {
+	min, max := vals[0], vals[0]
+	for _, v := range vals {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	n := len(vals)
+	forWidth := bits.Len64(max - min)
+	best := packPlan{mode: packFOR, width: forWidth, base: min, cost: expGolombLen(min) + n*forWidth}
+
+	// delta + zig-zag
+	if n > 1 {
+		dw := 0
+		for i := 1; i < n; i++ {
+			if l := bits.Len64(ZigZag(int64(vals[i] - vals[i-1]))); l > dw {
+				dw = l
+			}
+		}
+		if c := expGolombLen(vals[0]) + (n-1)*dw; c < best.cost {
+			best = packPlan{mode: packDelta, width: dw, base: vals[0], cost: c}
+		}
+	}
+
+	// exceptions, count how many values need more then each width
+	var need [65]int
+	for _, v := range vals {
+		need[bits.Len64(v-min)]++
+	}
+	over := 0
+	for w := forWidth - 1; w >= 0; w-- {
+		over += need[w+1]
+		exWidth := forWidth - w
+		c := expGolombLen(min) + expGolombLen(uint64(over)) + 7 + n*w + over*(7+exWidth)
+		if c < best.cost {
+			best = packPlan{mode: packPFOR, width: w, base: min, exWidth: exWidth, cost: c}
+		}
+	}
+	return best
+}
+
+// PackUint64s bit pack vals into w
+func PackUint64s(w BitsWriter, vals []uint64) {
+	WriteExpGolomb(w, uint64(len(vals)))
+	for start := 0; start < len(vals); start += PackBlockSize {
+		end := start + PackBlockSize
+		if end > len(vals) {
+			end = len(vals)
+		}
+		packBlock(w, vals[start:end])
+	}
+}
+
+func packBlock(w BitsWriter, vals []uint64) {
+	p := planBlock(vals)
+	w.WriteBits(uint64(p.mode), 2)
+	w.WriteBits(uint64(len(vals)-1), 7)
+	w.WriteBits(uint64(p.width), 7)
+	WriteExpGolomb(w, p.base)
+
+	switch p.mode {
+	case packFOR:
+		for _, v := range vals {
+			w.WriteBits(v-p.base, p.width)
+		}
+	case packDelta:
+		for i := 1; i < len(vals); i++ {
+			w.WriteBits(ZigZag(int64(vals[i]-vals[i-1])), p.width)
+		}
+	case packPFOR:
+		var ex []int
+		for i, v := range vals {
+			if bits.Len64(v-p.base) > p.width {
+				ex = append(ex, i)
+			}
+		}
+		WriteExpGolomb(w, uint64(len(ex)))
+		w.WriteBits(uint64(p.exWidth), 7)
+		for _, i := range ex {
+			w.WriteBits(uint64(i), 7)
+			w.WriteBits((vals[i]-p.base)>>uint(p.width), p.exWidth)
+		}
+		for _, v := range vals {
+			w.WriteBits(v-p.base, p.width)
+		}
+	}
+}
+
+// packedBlock a parsed block header
+type packedBlock struct {
+	mode  int
+	n     int
+	width int
+	base  uint64
+
+	exWidth int
+	exIdx   []uint8
+	exHigh  []uint64
+
+	// bit offset of the values
+	pos int64
+}
+
+// readPackedHeader read a block header up to the values
+func readPackedHeader(r BitsReader) (packedBlock, error) {
+	var b packedBlock
+	var hdr [3]uint64
+	for i, nbits := range []int{2, 7, 7} {
+		u, err := r.ReadBits(nbits)
+		if err != nil {
+			return b, err
+		}
+		hdr[i] = u
+	}
+	b.mode, b.n, b.width = int(hdr[0]), int(hdr[1])+1, int(hdr[2])
+	if b.mode > packPFOR || b.width > 64 {
+		return b, ErrBadPacked
+	}
+	base, err := ReadExpGolomb(r)
+	if err != nil {
+		return b, err
+	}
+	b.base = base
+
+	if b.mode == packPFOR {
+		nex, err := ReadExpGolomb(r)
+		if err != nil {
+			return b, err
+		}
+		if nex > uint64(b.n) {
+			return b, ErrBadPacked
+		}
+		exWidth, err := r.ReadBits(7)
+		if err != nil {
+			return b, err
+		}
+		if b.width+int(exWidth) > 64 {
+			return b, ErrBadPacked
+		}
+		b.exWidth = int(exWidth)
+		b.exIdx = make([]uint8, nex)
+		b.exHigh = make([]uint64, nex)
+		for i := range b.exIdx {
+			idx, err := r.ReadBits(7)
+			if err != nil {
+				return b, err
+			}
+			if int(idx) >= b.n {
+				return b, ErrBadPacked
+			}
+			high, err := r.ReadBits(b.exWidth)
+			if err != nil {
+				return b, err
+			}
+			b.exIdx[i], b.exHigh[i] = uint8(idx), high
+		}
+	}
+	return b, nil
+}
+
+// valueBits the number of bits of values in the block
+func (b *packedBlock) valueBits() int64 {
+	if b.mode == packDelta {
+		return int64(b.n-1) * int64(b.width)
+	}
+	return int64(b.n) * int64(b.width)
+}
+
+// value the i-th value from its low bits
+func (b *packedBlock) value(i int, low uint64) uint64 {
+	if b.mode == packPFOR {
+		for j, idx := range b.exIdx {
+			if int(idx) == i {
+				low |= b.exHigh[j] << uint(b.width)
+				break
+			}
+		}
+	}
+	return b.base + low
+}
+
+// UnpackUint64s read values written by PackUint64s
+func UnpackUint64s(r BitsReader) ([]uint64, error) {
+	n, err := ReadExpGolomb(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > 1<<40 {
+		return nil, ErrBadPacked
+	}
+	// n comes off the wire, do not trust it for the allocation
+	vals := make([]uint64, 0, min(n, 1<<16))
+	for uint64(len(vals)) < n {
+		b, err := readPackedHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(vals)+b.n) > n {
+			return nil, ErrBadPacked
+		}
+		if b.mode == packDelta {
+			v := b.base
+			vals = append(vals, v)
+			for i := 1; i < b.n; i++ {
+				d, err := r.ReadBits(b.width)
+				if err != nil {
+					return nil, err
+				}
+				v += uint64(UnZigZag(d))
+				vals = append(vals, v)
+			}
+			continue
+		}
+		for i := 0; i < b.n; i++ {
+			low, err := r.ReadBits(b.width)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, b.value(i, low))
+		}
+	}
+	return vals, nil
+}
+
+// Packed random access to values written by PackUint64s
+type Packed struct {
+	r      Reader
+	n      int
+	blocks []packedBlock
+}
+
+// OpenPacked index the packed values at the current position of r, r is left
+// just past them
+func OpenPacked(r *Reader) (*Packed, error) {
+	n, err := ReadExpGolomb(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > 1<<40 {
+		return nil, ErrBadPacked
+	}
+	p := &Packed{n: int(n)}
+	for got := 0; got < p.n; {
+		b, err := readPackedHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		// every block but the last is full, Get counts on it
+		if got+b.n > p.n || (b.n != PackBlockSize && got+b.n != p.n) {
+			return nil, ErrBadPacked
+		}
+		b.pos = r.Position()
+		if err := r.SkipBits(b.valueBits()); err != nil {
+			return nil, err
+		}
+		p.blocks = append(p.blocks, b)
+		got += b.n
+	}
+	p.r = *r
+	return p, nil
+}
+
+// Len the number of values
+func (p *Packed) Len() int {
+	return p.n
+}
+
+// Get the i-th value
+func (p *Packed) Get(i int) (uint64, error) {
+	if i < 0 || i >= p.n {
+		return 0, ErrPackedIndex
+	}
+	b := &p.blocks[i/PackBlockSize]
+	i %= PackBlockSize
+	r := p.r
+
+	if b.mode == packDelta {
+		if err := r.SeekBit(b.pos); err != nil {
+			return 0, err
+		}
+		v := b.base
+		for j := 1; j <= i; j++ {
+			d, err := r.ReadBits(b.width)
+			if err != nil {
+				return 0, err
+			}
+			v += uint64(UnZigZag(d))
+		}
+		return v, nil
+	}
+
+	if err := r.SeekBit(b.pos + int64(i)*int64(b.width)); err != nil {
+		return 0, err
+	}
+	low, err := r.ReadBits(b.width)
+	if err != nil {
+		return 0, err
+	}
+	return b.value(i, low), nil
+}
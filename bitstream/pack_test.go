@@ -0,0 +1,88 @@
+package bitstream
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func checkPacked(t *testing.T, name string, vals []uint64) int64 {
+	w := NewWriter(0)
+	PackUint64s(w, vals)
+	w.WriteBits(0x5, 3) // something after, to make sure we stop in the right spot
+
+	got, err := UnpackUint64s(w.Reader())
+	if err != nil {
+		t.Fatalf("%s: unpack failed: %v", name, err)
+	}
+	if len(got) != len(vals) {
+		t.Fatalf("%s: got %d values wanted %d", name, len(got), len(vals))
+	}
+	for i := range vals {
+		if got[i] != vals[i] {
+			t.Fatalf("%s: value %d got %d wanted %d", name, i, got[i], vals[i])
+		}
+	}
+
+	r := w.Reader()
+	p, err := OpenPacked(r)
+	if err != nil {
+		t.Fatalf("%s: open failed: %v", name, err)
+	}
+	if u, err := r.ReadBits(3); err != nil || u != 0x5 {
+		t.Fatalf("%s: reader not left after the packed values", name)
+	}
+	if p.Len() != len(vals) {
+		t.Fatalf("%s: packed len %d != %d", name, p.Len(), len(vals))
+	}
+	for _, i := range rand.Perm(len(vals)) {
+		v, err := p.Get(i)
+		if err != nil || v != vals[i] {
+			t.Fatalf("%s: get %d got %d (%v) wanted %d", name, i, v, err, vals[i])
+		}
+	}
+	if _, err := p.Get(len(vals)); err != ErrPackedIndex {
+		t.Fatalf("%s: expected ErrPackedIndex got %v", name, err)
+	}
+	return r.Position()
+}
+
+func TestPackUint64s(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+
+	checkPacked(t, "empty", nil)
+	checkPacked(t, "one", []uint64{42})
+	checkPacked(t, "extremes", []uint64{0, math.MaxUint64, 0, math.MaxUint64, 1})
+
+	// sorted postings, should go delta
+	postings := make([]uint64, 1000)
+	v := uint64(1000000)
+	for i := range postings {
+		v += uint64(rnd.Intn(16))
+		postings[i] = v
+	}
+	size := checkPacked(t, "postings", postings)
+	if size > int64(len(postings))*6 {
+		t.Fatalf("postings packed to %d bits", size)
+	}
+
+	// small values with the odd outlier, should go PFOR
+	outliers := make([]uint64, 1000)
+	for i := range outliers {
+		outliers[i] = uint64(rnd.Intn(8))
+		if i%100 == 0 {
+			outliers[i] = rnd.Uint64()
+		}
+	}
+	size = checkPacked(t, "outliers", outliers)
+	if size > int64(len(outliers))*5 {
+		t.Fatalf("outliers packed to %d bits", size)
+	}
+
+	// plain random
+	random := make([]uint64, 777)
+	for i := range random {
+		random[i] = rnd.Uint64() >> uint(rnd.Intn(64))
+	}
+	checkPacked(t, "random", random)
+}
@@ -0,0 +1,100 @@
+package rangecoder
+
+import (
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+// DefaultShift the adaption rate of NewAdaptiveModel
+const DefaultShift = 5
+
+// AdaptiveModel moves its probability a 1/2^Shift of the way towards
+// each bit it sees (smaller shifts adapt faster, larger ones are more precise)
+type AdaptiveModel struct {
+	p     uint32
+	shift uint
+}
+
+// NewAdaptiveModel a model starting at 50/50 with DefaultShift
+func NewAdaptiveModel() *AdaptiveModel {
+	return NewAdaptiveModelShift(DefaultShift)
+}
+
+// NewAdaptiveModelShift a model starting at 50/50 adapting at shift
+func NewAdaptiveModelShift(shift uint) *AdaptiveModel {
+	return &AdaptiveModel{p: ProbMax / 2, shift: shift}
+}
+
+// P0 the probability of a zero
+func (m *AdaptiveModel) P0() uint32 {
+	return m.p
+}
+
+// Update the model
+func (m *AdaptiveModel) Update(bit bitstream.Bit) {
+	if bit {
+		m.p -= m.p >> m.shift
+	} else {
+		m.p += (ProbMax - m.p) >> m.shift
+	}
+}
+
+// Reset back to 50/50
+func (m *AdaptiveModel) Reset() {
+	m.p = ProbMax / 2
+}
+
+// FixedModel a static probability of a zero
+type FixedModel uint32
+
+// P0 the probability of a zero
+func (m FixedModel) P0() uint32 {
+	return uint32(m)
+}
+
+// Update does nothing
+func (m FixedModel) Update(bit bitstream.Bit) {}
+
+// TreeModel a binary tree of models for coding nbits wide symbols, each bit
+// is coded with a model picked by the bits before it (like LZMA's bit trees)
+type TreeModel struct {
+	nbits  int
+	models []AdaptiveModel
+}
+
+// NewTreeModel a tree model for nbits (at most 24) wide symbols
+func NewTreeModel(nbits int) *TreeModel {
+	t := &TreeModel{nbits: nbits, models: make([]AdaptiveModel, 1<<uint(nbits))}
+	for i := range t.models {
+		t.models[i] = AdaptiveModel{p: ProbMax / 2, shift: DefaultShift}
+	}
+	return t
+}
+
+// Encode the low nbits of u
+func (t *TreeModel) Encode(e *Encoder, u uint64) {
+	m := 1
+	for i := t.nbits - 1; i >= 0; i-- {
+		bit := bitstream.Bit((u>>uint(i))&1 == 1)
+		e.Encode(bit, &t.models[m])
+		m <<= 1
+		if bit {
+			m |= 1
+		}
+	}
+}
+
+// Decode a symbol
+func (t *TreeModel) Decode(d *Decoder) (uint64, error) {
+	m := 1
+	for i := 0; i < t.nbits; i++ {
+		bit, err := d.Decode(&t.models[m])
+		if err != nil {
+			return 0, err
+		}
+		m <<= 1
+		if bit {
+			m |= 1
+		}
+	}
+	return uint64(m - 1<<uint(t.nbits)), nil
+}
@@ -0,0 +1,190 @@
+/**
+rangecoder
+
+An adaptive binary range (arithmetic) coder that writes to, and reads from, the
+bitstream package's writers and readers
+
+The coder is the LZMA style one: a 32 bit range, carry propagation via a cached
+byte and probabilities in ProbBits bits of precision.  The probability of each
+bit comes from a Model, so anything from a fixed probability to context mixing
+can be plugged in, the decoder must use the same models in the same order.
+
+	w := bitstream.NewWriter(1024)
+	enc := rangecoder.NewEncoder(w)
+	m := rangecoder.NewAdaptiveModel()
+	for _, bit := range bits {
+		enc.Encode(bit, m)
+	}
+	enc.Flush()
+
+	dec, err := rangecoder.NewDecoder(w.Reader())
+	m = rangecoder.NewAdaptiveModel()
+	bit, err := dec.Decode(m)
+*/
+
+package rangecoder
+
+import (
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+// ProbBits the precision of the probabilities given by a Model
+const ProbBits = 12
+
+// ProbMax probabilities are in (0, ProbMax)
+const ProbMax = 1 << ProbBits
+
+// renormalize when the range falls below this
+const topValue = 1 << 24
+
+// Model a probability model for a binary symbol
+type Model interface {
+	// P0 the probability of the next bit being a zero, scaled to (0, ProbMax)
+	P0() uint32
+	// Update tell the model what the bit turned out to be
+	Update(bit bitstream.Bit)
+}
+
+// clamp keep a models probability in the range the coder can deal with
+func clamp(p uint32) uint32 {
+	if p == 0 {
+		return 1
+	}
+	if p >= ProbMax {
+		return ProbMax - 1
+	}
+	return p
+}
+
+// Encoder the range encoder
+type Encoder struct {
+	w bitstream.BitsWriter
+
+	low       uint64
+	rng       uint32
+	cache     byte
+	cacheSize int64
+}
+
+// NewEncoder a new encoder writing to w
+func NewEncoder(w bitstream.BitsWriter) *Encoder {
+	return &Encoder{w: w, rng: 0xffffffff, cacheSize: 1}
+}
+
+// Encode a bit with the probabilities from m (m is then updated)
+func (e *Encoder) Encode(bit bitstream.Bit, m Model) {
+	bound := (e.rng >> ProbBits) * clamp(m.P0())
+	if bit {
+		e.low += uint64(bound)
+		e.rng -= bound
+	} else {
+		e.rng = bound
+	}
+	m.Update(bit)
+
+	for e.rng < topValue {
+		e.rng <<= 8
+		e.shiftLow()
+	}
+}
+
+// EncodeBits encode the low nbits of u high bit first, all with the same model
+func (e *Encoder) EncodeBits(u uint64, nbits int, m Model) {
+	for i := nbits - 1; i >= 0; i-- {
+		e.Encode(bitstream.Bit((u>>uint(i))&1 == 1), m)
+	}
+}
+
+// shiftLow push the top byte of low out, holding back 0xff bytes until
+// we know if a carry is going to ripple into them
+func (e *Encoder) shiftLow() {
+	if uint32(e.low) < 0xff000000 || e.low>>32 != 0 {
+		carry := byte(e.low >> 32)
+		temp := e.cache
+		for {
+			e.w.WriteBits(uint64(temp+carry), 8)
+			temp = 0xff
+			e.cacheSize--
+			if e.cacheSize == 0 {
+				break
+			}
+		}
+		e.cache = byte(e.low >> 24)
+	}
+	e.cacheSize++
+	e.low = (e.low & 0x00ffffff) << 8
+}
+
+// Flush write out the rest of the state, must be called at the end of encoding
+func (e *Encoder) Flush() {
+	for i := 0; i < 5; i++ {
+		e.shiftLow()
+	}
+}
+
+// Decoder the range decoder
+type Decoder struct {
+	r bitstream.BitsReader
+
+	code uint32
+	rng  uint32
+}
+
+// NewDecoder a new decoder reading from r
+func NewDecoder(r bitstream.BitsReader) (*Decoder, error) {
+	d := &Decoder{r: r, rng: 0xffffffff}
+	// the first byte is always the encoders initial (zero) cache
+	for i := 0; i < 5; i++ {
+		if err := d.next(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *Decoder) next() error {
+	byt, err := d.r.ReadBits(8)
+	if err != nil {
+		return err
+	}
+	d.code = d.code<<8 | uint32(byt)
+	return nil
+}
+
+// Decode a bit with the probabilities from m (m is then updated)
+func (d *Decoder) Decode(m Model) (bitstream.Bit, error) {
+	bound := (d.rng >> ProbBits) * clamp(m.P0())
+	var bit bitstream.Bit
+	if d.code < bound {
+		d.rng = bound
+	} else {
+		d.code -= bound
+		d.rng -= bound
+		bit = bitstream.OneBit
+	}
+	m.Update(bit)
+
+	for d.rng < topValue {
+		d.rng <<= 8
+		if err := d.next(); err != nil {
+			return bit, err
+		}
+	}
+	return bit, nil
+}
+
+// DecodeBits decode nbits encoded with EncodeBits
+func (d *Decoder) DecodeBits(nbits int, m Model) (uint64, error) {
+	var u uint64
+	for i := 0; i < nbits; i++ {
+		bit, err := d.Decode(m)
+		if err != nil {
+			return 0, err
+		}
+		u <<= 1
+		if bit {
+			u |= 1
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,101 @@
+package rangecoder
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+func skewedBits(n int, pOne float64, seed int64) []bitstream.Bit {
+	rnd := rand.New(rand.NewSource(seed))
+	bits := make([]bitstream.Bit, n)
+	for i := range bits {
+		bits[i] = rnd.Float64() < pOne
+	}
+	return bits
+}
+
+func TestRangeCoderSkewed(t *testing.T) {
+	for _, p := range []float64{0.5, 0.2, 0.05, 0.01} {
+		bits := skewedBits(100000, p, 1)
+
+		w := bitstream.NewWriter(0)
+		enc := NewEncoder(w)
+		m := NewAdaptiveModel()
+		for _, b := range bits {
+			enc.Encode(b, m)
+		}
+		enc.Flush()
+
+		perSymbol := float64(w.BitLen()) / float64(len(bits))
+		t.Logf("p(1)=%v: %.4f bits/symbol", p, perSymbol)
+		if p <= 0.05 && perSymbol > 0.4 {
+			t.Fatalf("p(1)=%v compressed to %.4f bits/symbol", p, perSymbol)
+		}
+
+		dec, err := NewDecoder(w.Reader())
+		if err != nil {
+			t.Fatalf("new decoder failed: %v", err)
+		}
+		m = NewAdaptiveModel()
+		for i, b := range bits {
+			got, err := dec.Decode(m)
+			if err != nil {
+				t.Fatalf("decode %d failed: %v", i, err)
+			}
+			if got != b {
+				t.Fatalf("decode %d mismatch", i)
+			}
+		}
+	}
+}
+
+func TestRangeCoderModels(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	type sym struct {
+		u     uint64
+		nbits int
+		fixed bool
+	}
+	syms := make([]sym, 5000)
+	for i := range syms {
+		syms[i] = sym{u: uint64(rnd.Intn(256)) & 0x0f, nbits: 8, fixed: rnd.Intn(2) == 0}
+	}
+
+	// a streaming writer this time
+	buf := new(bytes.Buffer)
+	bw := bitstream.NewBitWriter(buf)
+	enc := NewEncoder(bw)
+	tree := NewTreeModel(8)
+	fixed := FixedModel(ProbMax / 4)
+	for _, s := range syms {
+		if s.fixed {
+			enc.EncodeBits(s.u, s.nbits, fixed)
+		} else {
+			tree.Encode(enc, s.u)
+		}
+	}
+	enc.Flush()
+	if err := bw.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	dec, err := NewDecoder(bitstream.NewBitReader(buf))
+	if err != nil {
+		t.Fatalf("new decoder failed: %v", err)
+	}
+	tree = NewTreeModel(8)
+	for i, s := range syms {
+		var u uint64
+		if s.fixed {
+			u, err = dec.DecodeBits(s.nbits, fixed)
+		} else {
+			u, err = tree.Decode(dec)
+		}
+		if err != nil || u != s.u {
+			t.Fatalf("symbol %d: got %d (%v) wanted %d", i, u, err, s.u)
+		}
+	}
+}
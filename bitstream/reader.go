@@ -0,0 +1,248 @@
+package bitstream
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrSeek the seek position is outside of the stream
+var ErrSeek = errors.New("bitstream: seek position out of range")
+
+// Reader a read only bit stream over a byte slice
+//
+// Reading only moves a bit offset, the underlying bytes are never modified so
+// any number of Readers can walk the same block (even in different go routines),
+// a single Reader is not thread safe
+type Reader struct {
+	// the data stream
+	stream []byte
+
+	// bit offset of the next read
+	pos int64
+
+	// number of valid bits in the stream
+	end int64
+
+	order BitOrder
+
+	strict bool
+}
+
+// NewReader new bit reader over all the bits in b
+func NewReader(b []byte) *Reader {
+	return &Reader{stream: b, end: int64(len(b)) * 8}
+}
+
+// NewReaderBits new bit reader over the first nbits of b
+func NewReaderBits(b []byte, nbits int64) *Reader {
+	if max := int64(len(b)) * 8; nbits > max {
+		nbits = max
+	}
+	if nbits < 0 {
+		nbits = 0
+	}
+	return &Reader{stream: b, end: nbits}
+}
+
+// Reset read all the bits in b from the start, the bit order and strict mode are kept
+func (r *Reader) Reset(b []byte) {
+	r.stream = b
+	r.pos = 0
+	r.end = int64(len(b)) * 8
+}
+
+// Bytes the underlying stream
+func (r *Reader) Bytes() []byte {
+	return r.stream
+}
+
+// SetOrder set the bit order (MSBFirst by default) for the bits read from now on
+func (r *Reader) SetOrder(o BitOrder) {
+	r.order = o
+}
+
+// Order the current bit order
+func (r *Reader) Order() BitOrder {
+	return r.order
+}
+
+// SetStrict in strict mode running out of bits is always a *ShortReadError, even at
+// the very end of the stream (where io.EOF is returned otherwise), reads never consume
+// anything unless they can be completed in either mode
+func (r *Reader) SetStrict(strict bool) {
+	r.strict = strict
+}
+
+// BitLen the number of readable bits in the stream
+func (r *Reader) BitLen() int64 {
+	return r.end
+}
+
+// Position the bit offset of the next read
+func (r *Reader) Position() int64 {
+	return r.pos
+}
+
+// Remaining the number of bits left to read
+func (r *Reader) Remaining() int64 {
+	return r.end - r.pos
+}
+
+// SeekBit move the read cursor to bit offset pos (0 <= pos <= BitLen)
+func (r *Reader) SeekBit(pos int64) error {
+	if pos < 0 || pos > r.end {
+		return ErrSeek
+	}
+	r.pos = pos
+	return nil
+}
+
+// SkipBits move the read cursor forward nbits, nothing is skipped if there are not enough bits
+func (r *Reader) SkipBits(nbits int64) error {
+	if nbits < 0 {
+		return ErrSeek
+	}
+	if err := r.check(nbits); err != nil {
+		return err
+	}
+	r.pos += nbits
+	return nil
+}
+
+// PeekBits read nbits (at most 64) without moving the read cursor
+func (r *Reader) PeekBits(nbits int) (uint64, error) {
+	if err := checkBitCount(nbits); err != nil {
+		return 0, err
+	}
+	if err := r.check(int64(nbits)); err != nil {
+		return 0, err
+	}
+	return r.read(nbits), nil
+}
+
+// ReadBit read a single bit
+func (r *Reader) ReadBit() (Bit, error) {
+	if err := r.check(1); err != nil {
+		return false, err
+	}
+	var d byte
+	if r.order == LSBFirst {
+		d = r.stream[r.pos>>3] & (1 << uint(r.pos&7))
+	} else {
+		d = r.stream[r.pos>>3] & (0x80 >> uint(r.pos&7))
+	}
+	r.pos++
+	return d != 0, nil
+}
+
+// ReadByte read a byte from the stream
+func (r *Reader) ReadByte() (byte, error) {
+	u, err := r.ReadBits(8)
+	return byte(u), err
+}
+
+// ReadBytes read n bytes from the stream
+func (r *Reader) ReadBytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrBitCount
+	}
+	if err := r.check(int64(n) * 8); err != nil {
+		return nil, err
+	}
+	byts := make([]byte, n)
+	for i := range byts {
+		byts[i] = byte(r.read(8))
+		r.pos += 8
+	}
+	return byts, nil
+}
+
+// ReadBits read nbits (at most 64) from the stream
+func (r *Reader) ReadBits(nbits int) (uint64, error) {
+	if err := checkBitCount(nbits); err != nil {
+		return 0, err
+	}
+	if err := r.check(int64(nbits)); err != nil {
+		return 0, err
+	}
+	u := r.read(nbits)
+	r.pos += int64(nbits)
+	return u, nil
+}
+
+// check there are nbits left to read, io.EOF if nothing is left (and not strict)
+// a *ShortReadError otherwise
+func (r *Reader) check(nbits int64) error {
+	avail := r.end - r.pos
+	if avail >= nbits {
+		return nil
+	}
+	if avail <= 0 && !r.strict {
+		return io.EOF
+	}
+	if avail < 0 {
+		avail = 0
+	}
+	return &ShortReadError{Requested: nbits, Available: avail}
+}
+
+// read nbits at the current position in the current order (does not move the position)
+func (r *Reader) read(nbits int) uint64 {
+	if r.order == LSBFirst {
+		return readBitsLSB(r.stream, r.pos, nbits)
+	}
+	return readBits(r.stream, r.pos, nbits)
+}
+
+// readBits read nbits starting at bit offset pos, MSB first, the caller
+// makes sure the bits are there
+func readBits(stream []byte, pos int64, nbits int) uint64 {
+	if nbits <= 0 {
+		return 0
+	}
+	i := pos >> 3
+	if int64(len(stream))-i >= 8 {
+		// a word at a time
+		off := uint(pos & 7)
+		w := binary.BigEndian.Uint64(stream[i:]) << off
+		if int(off)+nbits > 64 {
+			// the last few bits are in the 9th byte
+			w |= uint64(stream[i+8] >> (8 - off))
+		}
+		return w >> uint(64-nbits)
+	}
+
+	var u uint64
+	for nbits > 0 {
+		off := uint(pos & 7)
+		take := 8 - int(off)
+		if take > nbits {
+			take = nbits
+		}
+		byt := stream[pos>>3] << off
+		u = u<<uint(take) | uint64(byt>>uint(8-take))
+		pos += int64(take)
+		nbits -= take
+	}
+	return u
+}
+
+// readBitsLSB read nbits starting at bit offset pos, LSB first, the caller
+// makes sure the bits are there
+func readBitsLSB(stream []byte, pos int64, nbits int) uint64 {
+	var u uint64
+	got := 0
+	for got < nbits {
+		off := uint(pos & 7)
+		take := 8 - int(off)
+		if take > nbits-got {
+			take = nbits - got
+		}
+		byt := (stream[pos>>3] >> off) & (1<<uint(take) - 1)
+		u |= uint64(byt) << uint(got)
+		pos += int64(take)
+		got += take
+	}
+	return u
+}
@@ -0,0 +1,113 @@
+package bitstream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// seekReader is what Reader, BitStream and BitReader all have
+type seekReader interface {
+	BitsReader
+	Position() int64
+	PeekBits(int) (uint64, error)
+	SkipBits(int64) error
+	SeekBit(int64) error
+}
+
+func testBitsOffsets() []int64 {
+	var offs []int64
+	var at int64
+	for _, v := range testBits {
+		offs = append(offs, at)
+		at += int64(v.nbits)
+	}
+	return append(offs, at)
+}
+
+func checkSeek(t *testing.T, name string, r seekReader) {
+	offs := testBitsOffsets()
+
+	// peek then read the same thing
+	for i, v := range testBits {
+		p, err := r.PeekBits(v.nbits)
+		if err != nil || p != v.u {
+			t.Fatalf("%s: peek %d got %x (%v) wanted %x", name, i, p, err, v.u)
+		}
+		if r.Position() != offs[i] {
+			t.Fatalf("%s: peek moved the position %d != %d", name, r.Position(), offs[i])
+		}
+		u, err := r.ReadBits(v.nbits)
+		if err != nil || u != v.u {
+			t.Fatalf("%s: read %d got %x (%v) wanted %x", name, i, u, err, v.u)
+		}
+	}
+
+	// jump around backwards
+	for i := len(testBits) - 1; i >= 0; i-- {
+		if err := r.SeekBit(offs[i]); err != nil {
+			t.Fatalf("%s: seek failed: %v", name, err)
+		}
+		u, err := r.ReadBits(testBits[i].nbits)
+		if err != nil || u != testBits[i].u {
+			t.Fatalf("%s: read after seek %d got %x (%v) wanted %x", name, i, u, err, testBits[i].u)
+		}
+	}
+
+	// skip over a few
+	if err := r.SeekBit(0); err != nil {
+		t.Fatalf("%s: seek failed: %v", name, err)
+	}
+	if err := r.SkipBits(offs[5]); err != nil {
+		t.Fatalf("%s: skip failed: %v", name, err)
+	}
+	if r.Position() != offs[5] {
+		t.Fatalf("%s: skip position %d != %d", name, r.Position(), offs[5])
+	}
+	u, err := r.ReadBits(testBits[5].nbits)
+	if err != nil || u != testBits[5].u {
+		t.Fatalf("%s: read after skip got %x (%v) wanted %x", name, u, err, testBits[5].u)
+	}
+}
+
+func TestReaderSeek(t *testing.T) {
+	w := NewWriter(0)
+	writeTestBits(w)
+
+	checkSeek(t, "Reader", w.Reader())
+	checkSeek(t, "BitStream", NewBReader(w.Bytes()))
+	checkSeek(t, "BitReader", NewBitReaderSize(bytes.NewReader(w.Bytes()), 2))
+
+	r := w.Reader()
+	if err := r.SeekBit(r.BitLen() + 1); err != ErrSeek {
+		t.Fatalf("expected ErrSeek got %v", err)
+	}
+	if err := r.SkipBits(r.BitLen() + 1); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+	if r.Position() != 0 {
+		t.Fatalf("failed skip moved the position")
+	}
+}
+
+func TestBitReaderSkip(t *testing.T) {
+	w := NewWriter(0)
+	for i := 0; i < 100; i++ {
+		writeTestBits(w)
+	}
+	br := NewBitReaderSize(iotest.HalfReader(bytes.NewReader(w.Bytes())), 16)
+	stride := testBitsOffsets()[len(testBits)]
+	for i := 0; i < 10; i++ {
+		if err := br.SkipBits(stride * 9); err != nil {
+			t.Fatalf("skip failed: %v", err)
+		}
+		readTestBits(t, br)
+	}
+
+	// not seekable
+	if err := br.SeekBit(0); err != ErrNotSeekable {
+		t.Fatalf("expected ErrNotSeekable got %v", err)
+	}
+}
@@ -0,0 +1,497 @@
+package bitstream
+
+import (
+	"errors"
+	"io"
+)
+
+// default size of the byte buffers for the streaming reader/writer
+const defaultStreamBufferSize = 4096
+
+// how many (0, nil) reads in a row before giving up with io.ErrNoProgress
+const maxEmptyReads = 100
+
+// ErrNotSeekable the underlying io.Reader is not an io.Seeker
+var ErrNotSeekable = errors.New("bitstream: reader is not seekable")
+
+// BitWriter a bit writer on top of an io.Writer
+//
+// Complete bytes are buffered and written out as the buffer fills, Flush (or Close)
+// must be called at the end to pad out the final partial byte and write what is left.
+//
+// Write errors are sticky, once one happens all other writes are no-ops and the error
+// is returned from WriteByte, Flush, Close and Err
+type BitWriter struct {
+	w   io.Writer
+	buf []byte
+
+	// the current partial byte
+	cur byte
+	// how many bits are used in the current byte
+	count uint8
+
+	bitsWritten int64
+	err         error
+
+	order BitOrder
+}
+
+// NewBitWriter a new BitWriter writing to w
+func NewBitWriter(w io.Writer) *BitWriter {
+	return NewBitWriterSize(w, defaultStreamBufferSize)
+}
+
+// NewBitWriterSize a new BitWriter writing to w buffering up to size bytes at a time
+func NewBitWriterSize(w io.Writer, size int) *BitWriter {
+	if size <= 0 {
+		size = defaultStreamBufferSize
+	}
+	return &BitWriter{w: w, buf: make([]byte, 0, size)}
+}
+
+// Reset drop anything not yet flushed (and any error) and start writing to w, the
+// buffer is reused
+func (b *BitWriter) Reset(w io.Writer) {
+	b.w = w
+	b.buf = b.buf[:0]
+	b.cur, b.count = 0, 0
+	b.bitsWritten = 0
+	b.err = nil
+}
+
+// SetOrder set the bit order (MSBFirst by default) for the bits written from now on
+func (b *BitWriter) SetOrder(o BitOrder) {
+	b.order = o
+}
+
+// Order the current bit order
+func (b *BitWriter) Order() BitOrder {
+	return b.order
+}
+
+// BitLen the number of bits written (flushed or not), not counting the padding added by Flush
+func (b *BitWriter) BitLen() int64 {
+	return b.bitsWritten
+}
+
+// Err the first write error (if any)
+func (b *BitWriter) Err() error {
+	return b.err
+}
+
+// putByte add a complete byte to the buffer, flushing it if full
+func (b *BitWriter) putByte(byt byte) {
+	b.buf = append(b.buf, byt)
+	if len(b.buf) == cap(b.buf) {
+		b.flushBuffer()
+	}
+}
+
+func (b *BitWriter) flushBuffer() {
+	if b.err != nil || len(b.buf) == 0 {
+		return
+	}
+	_, b.err = b.w.Write(b.buf)
+	b.buf = b.buf[:0]
+}
+
+// WriteBit write a single bit
+func (b *BitWriter) WriteBit(bit Bit) {
+	if b.err != nil {
+		return
+	}
+	if bit {
+		if b.order == LSBFirst {
+			b.cur |= 1 << b.count
+		} else {
+			b.cur |= 0x80 >> b.count
+		}
+	}
+	b.count++
+	b.bitsWritten++
+	if b.count == 8 {
+		b.putByte(b.cur)
+		b.cur, b.count = 0, 0
+	}
+}
+
+// WriteByte write a byte to the stream
+func (b *BitWriter) WriteByte(byt byte) error {
+	b.WriteBits(uint64(byt), 8)
+	return b.err
+}
+
+// WriteBytes write a byte slice
+func (b *BitWriter) WriteBytes(bs []byte) int {
+	c := 0
+	for _, by := range bs {
+		if b.WriteByte(by) != nil {
+			break
+		}
+		c++
+	}
+	return c
+}
+
+// WriteBits write nbits to the stream
+func (b *BitWriter) WriteBits(u uint64, nbits int) {
+	if b.err != nil {
+		return
+	}
+	for nbits > 0 {
+		take := 8 - int(b.count)
+		if take > nbits {
+			take = nbits
+		}
+		nbits -= take
+		if b.order == LSBFirst {
+			b.cur |= byte(u&(1<<uint(take)-1)) << b.count
+			u >>= uint(take)
+		} else {
+			byt := byte((u >> uint(nbits)) & (1<<uint(take) - 1))
+			b.cur |= byt << uint(8-int(b.count)-take)
+		}
+		b.count += uint8(take)
+		b.bitsWritten += int64(take)
+		if b.count == 8 {
+			b.putByte(b.cur)
+			b.cur, b.count = 0, 0
+		}
+	}
+}
+
+// Flush pad the current partial byte with zeros and write all the buffered bytes
+func (b *BitWriter) Flush() error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.count > 0 {
+		b.buf = append(b.buf, b.cur)
+		b.cur, b.count = 0, 0
+	}
+	b.flushBuffer()
+	return b.err
+}
+
+// Close flush the stream, the underlying io.Writer is not closed
+func (b *BitWriter) Close() error {
+	return b.Flush()
+}
+
+// BitReader a bit reader on top of an io.Reader, the underlying reader is read
+// from lazily in chunks as the bits are needed
+type BitReader struct {
+	r io.Reader
+
+	buf []byte
+	off int
+
+	// the current byte
+	cur byte
+	// how many unread bits are in the current byte
+	count uint8
+
+	bitsRead int64
+	err      error
+
+	order  BitOrder
+	strict bool
+}
+
+// NewBitReader a new BitReader reading from r
+func NewBitReader(r io.Reader) *BitReader {
+	return NewBitReaderSize(r, defaultStreamBufferSize)
+}
+
+// NewBitReaderSize a new BitReader reading from r at most size bytes at a time
+func NewBitReaderSize(r io.Reader, size int) *BitReader {
+	if size <= 0 {
+		size = defaultStreamBufferSize
+	}
+	return &BitReader{r: r, buf: make([]byte, 0, size)}
+}
+
+// Reset drop anything buffered (and any error) and start reading from r, the
+// buffer is reused
+func (b *BitReader) Reset(r io.Reader) {
+	b.r = r
+	b.buf = b.buf[:0]
+	b.off = 0
+	b.cur, b.count = 0, 0
+	b.bitsRead = 0
+	b.err = nil
+}
+
+// SetOrder set the bit order (MSBFirst by default) for the bits read from now on
+func (b *BitReader) SetOrder(o BitOrder) {
+	b.order = o
+}
+
+// Order the current bit order
+func (b *BitReader) Order() BitOrder {
+	return b.order
+}
+
+// SetStrict in strict mode a read that can not be completed consumes nothing (the bits
+// are buffered up before hand) and running out of bits is always a *ShortReadError,
+// otherwise the bits of a short read are lost and io.EOF is returned at the very end
+func (b *BitReader) SetStrict(strict bool) {
+	b.strict = strict
+}
+
+// buffered the number of bits that can be read without touching the underlying reader
+func (b *BitReader) buffered() int64 {
+	return int64(b.count) + int64(len(b.buf)-b.off)*8
+}
+
+// short the error for running out of bits after got of nbits
+func (b *BitReader) short(nbits, got int64, err error) error {
+	if err != io.EOF {
+		return err
+	}
+	if got == 0 && !b.strict {
+		return io.EOF
+	}
+	return &ShortReadError{Requested: nbits, Available: got}
+}
+
+// fill load up the next byte to read from
+func (b *BitReader) fill() error {
+	empty := 0
+	for b.off >= len(b.buf) {
+		if b.err != nil {
+			return b.err
+		}
+		n, err := b.r.Read(b.buf[:cap(b.buf)])
+		b.buf = b.buf[:n]
+		b.off = 0
+		if err != nil {
+			b.err = err
+		} else if n == 0 {
+			empty++
+			if empty >= maxEmptyReads {
+				b.err = io.ErrNoProgress
+			}
+		}
+	}
+	b.cur = b.buf[b.off]
+	b.off++
+	b.count = 8
+	return nil
+}
+
+// ensure try to get the buffer to hold at least nbits past the current byte
+// so they can be looked at without a refill, any read error is kept for later
+func (b *BitReader) ensure(nbits int) {
+	need := (nbits - int(b.count) + 7) / 8
+	if need <= len(b.buf)-b.off {
+		return
+	}
+	if cap(b.buf) < need {
+		nb := make([]byte, len(b.buf)-b.off, need)
+		copy(nb, b.buf[b.off:])
+		b.buf = nb
+	} else {
+		b.buf = b.buf[:copy(b.buf[:cap(b.buf)], b.buf[b.off:])]
+	}
+	b.off = 0
+
+	empty := 0
+	for len(b.buf) < need && b.err == nil {
+		n, err := b.r.Read(b.buf[len(b.buf):cap(b.buf)])
+		b.buf = b.buf[:len(b.buf)+n]
+		if err != nil {
+			b.err = err
+		} else if n == 0 {
+			empty++
+			if empty >= maxEmptyReads {
+				b.err = io.ErrNoProgress
+			}
+		}
+	}
+}
+
+// Position the bit offset of the next read
+func (b *BitReader) Position() int64 {
+	return b.bitsRead
+}
+
+// PeekBits read nbits (at most 64) without moving the read cursor
+func (b *BitReader) PeekBits(nbits int) (uint64, error) {
+	if err := checkBitCount(nbits); err != nil {
+		return 0, err
+	}
+	b.ensure(nbits)
+	cur, count, off, bitsRead := b.cur, b.count, b.off, b.bitsRead
+	u, err := b.ReadBits(nbits)
+	b.cur, b.count, b.off, b.bitsRead = cur, count, off, bitsRead
+	return u, err
+}
+
+// SkipBits move the read cursor forward nbits, if the stream runs out first (even
+// in strict mode) everything up to the end is skipped and a *ShortReadError returned
+func (b *BitReader) SkipBits(nbits int64) error {
+	if nbits < 0 {
+		return ErrSeek
+	}
+	if nbits <= int64(b.count) {
+		_, err := b.ReadBits(int(nbits))
+		return err
+	}
+
+	// the rest of the current byte, then whole bytes, then what is left over
+	want := nbits
+	nbits -= int64(b.count)
+	b.bitsRead += int64(b.count)
+	b.cur, b.count = 0, 0
+
+	nbytes := nbits / 8
+	if buffered := int64(len(b.buf) - b.off); nbytes <= buffered {
+		b.off += int(nbytes)
+	} else {
+		b.off = len(b.buf)
+		var n int64
+		err := b.err
+		if err == nil {
+			n, err = io.CopyN(io.Discard, b.r, nbytes-buffered)
+		}
+		if err != nil {
+			b.bitsRead += (buffered + n) * 8
+			b.err = err
+			return &ShortReadError{Requested: want, Available: want - nbits + (buffered+n)*8}
+		}
+	}
+	b.bitsRead += nbytes * 8
+
+	rest := nbits % 8
+	if _, err := b.ReadBits(int(rest)); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			var se *ShortReadError
+			got := int64(0)
+			if errors.As(err, &se) {
+				got = se.Available
+			}
+			return &ShortReadError{Requested: want, Available: want - rest + got}
+		}
+		return err
+	}
+	return nil
+}
+
+// SeekBit move the read cursor to bit offset pos, the underlying reader must be an io.Seeker
+// (and is assumed to have been at offset 0 when the BitReader was made)
+func (b *BitReader) SeekBit(pos int64) error {
+	if pos < 0 {
+		return ErrSeek
+	}
+	sk, ok := b.r.(io.Seeker)
+	if !ok {
+		return ErrNotSeekable
+	}
+	if _, err := sk.Seek(pos/8, io.SeekStart); err != nil {
+		return err
+	}
+	b.buf = b.buf[:0]
+	b.off = 0
+	b.cur, b.count = 0, 0
+	b.err = nil
+	b.bitsRead = pos - pos%8
+	_, err := b.ReadBits(int(pos % 8))
+	return err
+}
+
+// ReadBit read a single bit
+func (b *BitReader) ReadBit() (Bit, error) {
+	if b.count == 0 {
+		if err := b.fill(); err != nil {
+			return false, b.short(1, 0, err)
+		}
+	}
+	var d byte
+	if b.order == LSBFirst {
+		d = b.cur & (1 << (8 - b.count))
+	} else {
+		d = b.cur & (1 << (b.count - 1))
+	}
+	b.count--
+	b.bitsRead++
+	return d != 0, nil
+}
+
+// ReadByte read a byte from the stream
+func (b *BitReader) ReadByte() (byte, error) {
+	u, err := b.ReadBits(8)
+	return byte(u), err
+}
+
+// ReadBytes read n bytes from the stream
+func (b *BitReader) ReadBytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrBitCount
+	}
+	want := int64(n) * 8
+	if b.strict {
+		b.ensure(int(want))
+		if avail := b.buffered(); avail < want {
+			return nil, b.short(want, avail, io.EOF)
+		}
+	}
+	byts := make([]byte, n)
+	for i := range byts {
+		u, err := b.ReadBits(8)
+		if err != nil {
+			got := int64(i) * 8
+			var se *ShortReadError
+			if errors.As(err, &se) {
+				got += se.Available
+			} else if err != io.EOF {
+				return nil, err
+			}
+			return nil, b.short(want, got, io.EOF)
+		}
+		byts[i] = byte(u)
+	}
+	return byts, nil
+}
+
+// ReadBits read nbits (at most 64) from the stream, see SetStrict for what happens
+// if the stream ends part way through
+func (b *BitReader) ReadBits(nbits int) (uint64, error) {
+	if err := checkBitCount(nbits); err != nil {
+		return 0, err
+	}
+	if b.strict {
+		b.ensure(nbits)
+		if avail := b.buffered(); avail < int64(nbits) {
+			err := b.err
+			if err == nil || err == io.EOF {
+				return 0, b.short(int64(nbits), avail, io.EOF)
+			}
+			return 0, err
+		}
+	}
+
+	var u uint64
+	got := 0
+	for got < nbits {
+		if b.count == 0 {
+			if err := b.fill(); err != nil {
+				return 0, b.short(int64(nbits), int64(got), err)
+			}
+		}
+		take := int(b.count)
+		if take > nbits-got {
+			take = nbits - got
+		}
+		mask := byte(1<<uint(take) - 1)
+		if b.order == LSBFirst {
+			u |= uint64((b.cur>>(8-b.count))&mask) << uint(got)
+		} else {
+			u = u<<uint(take) | uint64((b.cur>>(b.count-uint8(take)))&mask)
+		}
+		b.count -= uint8(take)
+		b.bitsRead += int64(take)
+		got += take
+	}
+	return u, nil
+}
@@ -0,0 +1,95 @@
+package bitstream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestBitWriterReader(t *testing.T) {
+	buf := new(bytes.Buffer)
+	bw := NewBitWriterSize(buf, 3)
+	for i := 0; i < 10; i++ {
+		writeTestBits(bw)
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	// same bits as the in memory writer
+	w := NewWriter(0)
+	for i := 0; i < 10; i++ {
+		writeTestBits(w)
+	}
+	want := w.Bytes()[:(w.validBits()+7)/8]
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("stream encoding differs from Writer: %x != %x", buf.Bytes(), want)
+	}
+	if bw.BitLen() != w.BitLen() || bw.BitLen()%8 == 0 {
+		t.Fatalf("bit length %d wanted %d (without the padding)", bw.BitLen(), w.BitLen())
+	}
+	bw.Reset(buf)
+	if bw.BitLen() != 0 {
+		t.Fatalf("reset kept the bit length %d", bw.BitLen())
+	}
+
+	br := NewBitReaderSize(iotest.OneByteReader(bytes.NewReader(buf.Bytes())), 2)
+	for i := 0; i < 10; i++ {
+		readTestBits(t, br)
+	}
+
+	// the padding of the last byte, then nothing
+	for br.count > 0 {
+		if _, err := br.ReadBit(); err != nil {
+			t.Fatalf("padding read failed: %v", err)
+		}
+	}
+	if _, err := br.ReadBit(); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+}
+
+func TestBitReaderShort(t *testing.T) {
+	br := NewBitReader(bytes.NewReader([]byte{0xff}))
+	if _, err := br.ReadBits(4); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if _, err := br.ReadBits(8); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+}
+
+func TestBitWriterError(t *testing.T) {
+	bw := NewBitWriterSize(&errWriter{}, 1)
+	if err := bw.WriteByte(1); err != io.ErrClosedPipe {
+		t.Fatalf("expected the write error got %v", err)
+	}
+	bw.WriteBits(0xffff, 16)
+	if err := bw.Flush(); err != io.ErrClosedPipe {
+		t.Fatalf("expected the write error got %v", err)
+	}
+}
+
+type errWriter struct{}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestBitReaderWriterReset(t *testing.T) {
+	bw := NewBitWriterSize(&errWriter{}, 1)
+	bw.WriteByte(1)
+	buf := new(bytes.Buffer)
+	bw.Reset(buf)
+	writeTestBits(bw)
+	if err := bw.Close(); err != nil {
+		t.Fatalf("close after reset failed: %v", err)
+	}
+
+	br := NewBitReader(bytes.NewReader(nil))
+	br.ReadBit()
+	br.Reset(bytes.NewReader(buf.Bytes()))
+	readTestBits(t, br)
+}
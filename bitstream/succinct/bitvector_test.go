@@ -0,0 +1,65 @@
+package succinct
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+func TestBitVector(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	for _, density := range []float64{0, 0.001, 0.1, 0.5, 0.9, 1} {
+		for _, o := range []bitstream.BitOrder{bitstream.MSBFirst, bitstream.LSBFirst} {
+			n := 10000 + rnd.Intn(1000)
+			want := make([]bool, n)
+			w := bitstream.NewWriter(0)
+			w.SetOrder(o)
+			for i := range want {
+				want[i] = rnd.Float64() < density
+				w.WriteBit(bitstream.Bit(want[i]))
+			}
+
+			v, err := NewBitVector(w.Reader())
+			if err != nil {
+				t.Fatalf("new failed: %v", err)
+			}
+			if v.Len() != uint64(n) {
+				t.Fatalf("len %d != %d", v.Len(), n)
+			}
+
+			var ones, zeros uint64
+			for i, b := range want {
+				if v.Get(uint64(i)) != b {
+					t.Fatalf("get %d wrong", i)
+				}
+				if r := v.Rank1(uint64(i)); r != ones {
+					t.Fatalf("%v: rank1(%d) = %d wanted %d", density, i, r, ones)
+				}
+				if r := v.Rank0(uint64(i)); r != zeros {
+					t.Fatalf("%v: rank0(%d) = %d wanted %d", density, i, r, zeros)
+				}
+				if b {
+					if p, err := v.Select1(ones); err != nil || p != uint64(i) {
+						t.Fatalf("%v: select1(%d) = %d (%v) wanted %d", density, ones, p, err, i)
+					}
+					ones++
+				} else {
+					if p, err := v.Select0(zeros); err != nil || p != uint64(i) {
+						t.Fatalf("%v: select0(%d) = %d (%v) wanted %d", density, zeros, p, err, i)
+					}
+					zeros++
+				}
+			}
+			if v.Ones() != ones || v.Rank1(v.Len()) != ones {
+				t.Fatalf("ones %d != %d", v.Ones(), ones)
+			}
+			if _, err := v.Select1(ones); err != ErrOutOfRange {
+				t.Fatalf("expected ErrOutOfRange got %v", err)
+			}
+			if _, err := v.Select0(zeros); err != ErrOutOfRange {
+				t.Fatalf("expected ErrOutOfRange got %v", err)
+			}
+		}
+	}
+}
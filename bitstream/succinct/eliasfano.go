@@ -0,0 +1,251 @@
+package succinct
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+// ErrNotMonotone Elias-Fano needs a non decreasing sequence
+var ErrNotMonotone = errors.New("succinct: values are not in non-decreasing order")
+
+// ErrBadEliasFano the binary data is not a valid EliasFano
+var ErrBadEliasFano = errors.New("succinct: invalid elias-fano data")
+
+// EliasFano a monotone (non-decreasing) uint64 sequence in about 2 + log2(max/n) bits a value
+//
+// Each value is split into lowBits low bits, stored as is, and the rest, stored as
+// a unary coded gap in a rank/select bit vector (value i is a one at (v >> lowBits) + i)
+type EliasFano struct {
+	n       uint64
+	lowBits uint
+
+	low  bitstream.Reader
+	high *BitVector
+}
+
+// BuildEliasFano encode vals (which must be non-decreasing)
+func BuildEliasFano(vals []uint64) (*EliasFano, error) {
+	for i := 1; i < len(vals); i++ {
+		if vals[i] < vals[i-1] {
+			return nil, ErrNotMonotone
+		}
+	}
+
+	ef := &EliasFano{n: uint64(len(vals))}
+	var last uint64
+	if len(vals) > 0 {
+		last = vals[len(vals)-1]
+		// floor(log2(universe / n))
+		if q := last / ef.n; q > 0 {
+			ef.lowBits = uint(bits.Len64(q) - 1)
+		}
+	}
+
+	lw := bitstream.NewWriter(int(ef.n*uint64(ef.lowBits)/8) + 1)
+	for _, v := range vals {
+		lw.WriteBits(v, int(ef.lowBits))
+	}
+	ef.low = *lw.Reader()
+
+	nhigh := ef.n + last>>ef.lowBits + 1
+	words := make([]uint64, (nhigh+63)/64)
+	for i, v := range vals {
+		p := v>>ef.lowBits + uint64(i)
+		words[p/64] |= 1 << (p % 64)
+	}
+	ef.high = NewBitVectorWords(words, nhigh)
+	return ef, nil
+}
+
+// Len the number of values
+func (ef *EliasFano) Len() int {
+	return int(ef.n)
+}
+
+// lowAt the low bits of value i
+func (ef *EliasFano) lowAt(i uint64) uint64 {
+	if ef.lowBits == 0 {
+		return 0
+	}
+	r := ef.low
+	r.SeekBit(int64(i) * int64(ef.lowBits))
+	u, _ := r.ReadBits(int(ef.lowBits))
+	return u
+}
+
+// Get the i-th value
+func (ef *EliasFano) Get(i int) (uint64, error) {
+	if i < 0 || uint64(i) >= ef.n {
+		return 0, ErrOutOfRange
+	}
+	p, err := ef.high.Select1(uint64(i))
+	if err != nil {
+		return 0, err
+	}
+	return (p-uint64(i))<<ef.lowBits | ef.lowAt(uint64(i)), nil
+}
+
+// NextGEQ the first value >= x and its index, ok is false if there is none
+func (ef *EliasFano) NextGEQ(x uint64) (int, uint64, bool) {
+	if ef.n == 0 {
+		return 0, 0, false
+	}
+	hx := x >> ef.lowBits
+	maxHigh := ef.high.Len() - ef.n - 1
+	if hx > maxHigh {
+		return 0, 0, false
+	}
+
+	// jump to the first value in x's high bucket
+	var i, p uint64
+	if hx > 0 {
+		z, err := ef.high.Select0(hx - 1)
+		if err != nil {
+			return 0, 0, false
+		}
+		i = z + 1 - hx
+		p = z + 1
+	}
+
+	for i < ef.n {
+		p, _ = ef.high.nextOne(p)
+		v := (p-i)<<ef.lowBits | ef.lowAt(i)
+		if v >= x {
+			return int(i), v, true
+		}
+		i++
+		p++
+	}
+	return 0, 0, false
+}
+
+// Iter an iterator over the values from the start
+func (ef *EliasFano) Iter() *EliasFanoIter {
+	return &EliasFanoIter{ef: ef, i: -1}
+}
+
+// IterFrom an iterator starting at the first value >= x
+func (ef *EliasFano) IterFrom(x uint64) *EliasFanoIter {
+	i, _, ok := ef.NextGEQ(x)
+	if !ok {
+		return &EliasFanoIter{ef: ef, i: int(ef.n)}
+	}
+	p, _ := ef.high.Select1(uint64(i))
+	return &EliasFanoIter{ef: ef, i: i - 1, pos: p}
+}
+
+// EliasFanoIter walks the values in order
+type EliasFanoIter struct {
+	ef *EliasFano
+	i  int
+	// where to look for the next one in the high bits
+	pos uint64
+	v   uint64
+}
+
+// Next move to the next value, false at the end
+func (it *EliasFanoIter) Next() bool {
+	if it.i+1 >= int(it.ef.n) {
+		it.i = int(it.ef.n)
+		return false
+	}
+	it.i++
+	p, _ := it.ef.high.nextOne(it.pos)
+	it.v = (p-uint64(it.i))<<it.ef.lowBits | it.ef.lowAt(uint64(it.i))
+	it.pos = p + 1
+	return true
+}
+
+// Value the current value
+func (it *EliasFanoIter) Value() uint64 {
+	return it.v
+}
+
+// Index the index of the current value
+func (it *EliasFanoIter) Index() int {
+	return it.i
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, it is a
+// bitstream.BitStream (so it gets its checksum) of
+//
+//	n (Exp-Golomb), lowBits (7 bits), low bits, high bit count (Exp-Golomb), high bits
+func (ef *EliasFano) MarshalBinary() ([]byte, error) {
+	bs := bitstream.NewBWriter(int(ef.n*uint64(ef.lowBits+2)/8) + 16)
+	bitstream.WriteExpGolomb(bs, ef.n)
+	bs.WriteBits(uint64(ef.lowBits), 7)
+	low := ef.low
+	low.SeekBit(0)
+	for left := low.BitLen(); left > 0; left -= 64 {
+		nbits := 64
+		if left < 64 {
+			nbits = int(left)
+		}
+		u, _ := low.ReadBits(nbits)
+		bs.WriteBits(u, nbits)
+	}
+	bitstream.WriteExpGolomb(bs, ef.high.Len())
+	writeWords(bs, ef.high.Words(), ef.high.Len())
+	return bs.MarshalBinary()
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface
+func (ef *EliasFano) UnmarshalBinary(b []byte) error {
+	bs := new(bitstream.BitStream)
+	if err := bs.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	r := bs.Reader()
+
+	n, err := bitstream.ReadExpGolomb(r)
+	if err != nil {
+		return err
+	}
+	lowBits, err := r.ReadBits(7)
+	if err != nil {
+		return err
+	}
+	if lowBits > 63 || n > uint64(r.Remaining()) {
+		return ErrBadEliasFano
+	}
+	lowLen := int64(n) * int64(lowBits)
+	if lowLen > r.Remaining() {
+		return ErrBadEliasFano
+	}
+	lw := bitstream.NewWriter(int(lowLen/8) + 1)
+	for left := lowLen; left > 0; left -= 64 {
+		nbits := 64
+		if left < 64 {
+			nbits = int(left)
+		}
+		u, err := r.ReadBits(nbits)
+		if err != nil {
+			return err
+		}
+		lw.WriteBits(u, nbits)
+	}
+
+	nhigh, err := bitstream.ReadExpGolomb(r)
+	if err != nil {
+		return err
+	}
+	if nhigh > uint64(r.Remaining()) || nhigh < n+1 {
+		return ErrBadEliasFano
+	}
+	words, err := readWords(r, int64(nhigh))
+	if err != nil {
+		return err
+	}
+	high := NewBitVectorWords(words, nhigh)
+	if high.Ones() != n {
+		return ErrBadEliasFano
+	}
+
+	ef.n = n
+	ef.lowBits = uint(lowBits)
+	ef.low = *lw.Reader()
+	ef.high = high
+	return nil
+}
@@ -0,0 +1,101 @@
+package succinct
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func checkEliasFano(t *testing.T, name string, vals []uint64) {
+	ef, err := BuildEliasFano(vals)
+	if err != nil {
+		t.Fatalf("%s: build failed: %v", name, err)
+	}
+
+	bin, err := ef.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: marshal failed: %v", name, err)
+	}
+	ef2 := new(EliasFano)
+	if err := ef2.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("%s: unmarshal failed: %v", name, err)
+	}
+
+	for _, e := range []*EliasFano{ef, ef2} {
+		if e.Len() != len(vals) {
+			t.Fatalf("%s: len %d != %d", name, e.Len(), len(vals))
+		}
+		for i, v := range vals {
+			if got, err := e.Get(i); err != nil || got != v {
+				t.Fatalf("%s: get %d = %d (%v) wanted %d", name, i, got, err, v)
+			}
+		}
+		if _, err := e.Get(len(vals)); err != ErrOutOfRange {
+			t.Fatalf("%s: expected ErrOutOfRange got %v", name, err)
+		}
+
+		it := e.Iter()
+		for i, v := range vals {
+			if !it.Next() || it.Value() != v || it.Index() != i {
+				t.Fatalf("%s: iter %d = %d wanted %d", name, it.Index(), it.Value(), v)
+			}
+		}
+		if it.Next() {
+			t.Fatalf("%s: iter went past the end", name)
+		}
+
+		probes := []uint64{0, 1, math.MaxUint64}
+		for _, v := range vals {
+			probes = append(probes, v, v+1, v-1)
+		}
+		for _, x := range probes {
+			wi := sort.Search(len(vals), func(i int) bool { return vals[i] >= x })
+			i, v, ok := e.NextGEQ(x)
+			if wi == len(vals) {
+				if ok {
+					t.Fatalf("%s: NextGEQ(%d) found %d", name, x, v)
+				}
+				continue
+			}
+			if !ok || i != wi || v != vals[wi] {
+				t.Fatalf("%s: NextGEQ(%d) = %d, %d (%v) wanted %d, %d", name, x, i, v, ok, wi, vals[wi])
+			}
+			it := e.IterFrom(x)
+			if !it.Next() || it.Index() != wi || it.Value() != vals[wi] {
+				t.Fatalf("%s: IterFrom(%d) started at %d", name, x, it.Index())
+			}
+			if wi+1 < len(vals) && (!it.Next() || it.Value() != vals[wi+1]) {
+				t.Fatalf("%s: IterFrom(%d) next was %d", name, x, it.Value())
+			}
+		}
+	}
+}
+
+func TestEliasFano(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+
+	checkEliasFano(t, "empty", nil)
+	checkEliasFano(t, "one", []uint64{7})
+	checkEliasFano(t, "zeros", []uint64{0, 0, 0})
+	checkEliasFano(t, "dups", []uint64{1, 1, 5, 5, 5, 100, 100})
+	checkEliasFano(t, "big", []uint64{0, 1 << 40, math.MaxUint64 - 1, math.MaxUint64})
+
+	ids := make([]uint64, 5000)
+	v := uint64(0)
+	for i := range ids {
+		v += uint64(rnd.Intn(300))
+		ids[i] = v
+	}
+	checkEliasFano(t, "ids", ids)
+
+	dense := make([]uint64, 3000)
+	for i := range dense {
+		dense[i] = uint64(i)
+	}
+	checkEliasFano(t, "dense", dense)
+
+	if _, err := BuildEliasFano([]uint64{2, 1}); err != ErrNotMonotone {
+		t.Fatalf("expected ErrNotMonotone got %v", err)
+	}
+}
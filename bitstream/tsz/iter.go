@@ -0,0 +1,156 @@
+package tsz
+
+import (
+	"errors"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+// ErrBadBlock the block could not be decoded
+var ErrBadBlock = errors.New("tsz: invalid block")
+
+// Iter decodes the points in a block
+type Iter struct {
+	// T0 the block start time
+	T0 uint32
+
+	br *bitstream.Reader
+
+	t   uint32
+	val float64
+	dec bitstream.XORDecoder
+
+	tDelta uint32
+
+	started  bool
+	finished bool
+	err      error
+}
+
+// NewIterator an iterator over an encoded block, the block is not modified
+// so many iterators can share it
+func NewIterator(b []byte) (*Iter, error) {
+	return newIterator(bitstream.NewReader(b))
+}
+
+func newIterator(br *bitstream.Reader) (*Iter, error) {
+	t0, err := br.ReadBits(32)
+	if err != nil {
+		return nil, err
+	}
+	return &Iter{T0: uint32(t0), br: br}, nil
+}
+
+// Next move to the next point, false when there are no more points (or on error, see Err)
+func (it *Iter) Next() bool {
+
+	if it.err != nil || it.finished {
+		return false
+	}
+
+	if !it.started {
+		it.started = true
+		return it.first()
+	}
+
+	// read the delta-of-delta
+	var d byte
+	for i := 0; i < 4; i++ {
+		d <<= 1
+		bit, err := it.br.ReadBit()
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if bit == bitstream.ZeroBit {
+			break
+		}
+		d |= 1
+	}
+
+	var sz int
+	var dod int32
+	switch d {
+	case 0x00:
+		// dod == 0
+	case 0x02:
+		sz = 7
+	case 0x06:
+		sz = 9
+	case 0x0e:
+		sz = 12
+	case 0x0f:
+		bts, err := it.br.ReadBits(32)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if bts == endMarker {
+			it.finished = true
+			return false
+		}
+		dod = int32(bts)
+	}
+
+	if sz != 0 {
+		bts, err := it.br.ReadBits(sz)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if bts > (1 << uint(sz-1)) {
+			// sign extend
+			bts = bts - (1 << uint(sz))
+		}
+		dod = int32(bts)
+	}
+
+	tDelta := it.tDelta + uint32(dod)
+	it.tDelta = tDelta
+	it.t = it.t + it.tDelta
+
+	return it.nextValue()
+}
+
+func (it *Iter) first() bool {
+	tDelta, err := it.br.ReadBits(firstDeltaBits)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if tDelta == (1<<firstDeltaBits)-1 {
+		// an empty, finished, block starts with the end marker
+		rest, err := it.br.ReadBits(32 + 4 - firstDeltaBits)
+		if err == nil && rest == (1<<(32+4-firstDeltaBits))-1 {
+			it.finished = true
+			return false
+		}
+		it.err = ErrBadBlock
+		return false
+	}
+
+	it.tDelta = uint32(tDelta)
+	it.t = it.T0 + it.tDelta
+	return it.nextValue()
+}
+
+func (it *Iter) nextValue() bool {
+	v, err := it.dec.Decode(it.br)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.val = v
+	return true
+}
+
+// Values the current point
+func (it *Iter) Values() (uint32, float64) {
+	return it.t, it.val
+}
+
+// Err any error hit while decoding
+func (it *Iter) Err() error {
+	return it.err
+}
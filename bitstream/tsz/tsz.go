@@ -0,0 +1,164 @@
+/**
+tsz
+
+A Gorilla style (delta-of-delta timestamps, XOR'ed floats) time series block encoder
+living on top of bitstream.Writer/Reader
+
+shamelessly adapted from https://github.com/dgryski/go-tsz/blob/master/tsz.go
+
+The format for a block is
+
+	T0 (32 bits)
+	first delta (14 bits) + first value (64 bits)
+	[delta-of-delta + xor value]...
+	end marker ('1111' + 0xffffffff + '0')
+
+Note: the first point must be less then 2^14-1 seconds (~4.5 hours) from T0
+*/
+
+package tsz
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+// ErrFinished the series has been finished and can take no more points
+var ErrFinished = errors.New("tsz: series is finished")
+
+// ErrTimeOrder points must be pushed in time order
+var ErrTimeOrder = errors.New("tsz: time is before the last pushed point")
+
+// ErrFirstDelta the first point is too far from T0 to fit in the first delta
+var ErrFirstDelta = errors.New("tsz: first point is too far from T0")
+
+// end of stream marker, a delta-of-delta that can never be written as a real one
+const endMarker = 0xffffffff
+
+// bits used for the first delta
+const firstDeltaBits = 14
+
+// Series a block of time/value points
+type Series struct {
+	sync.Mutex
+
+	// T0 the block start time
+	T0 uint32
+
+	t uint32
+
+	bw       *bitstream.Writer
+	enc      bitstream.XOREncoder
+	started  bool
+	finished bool
+
+	tDelta uint32
+}
+
+// New a new series starting at t0
+func New(t0 uint32) *Series {
+	s := &Series{
+		T0: t0,
+		bw: bitstream.NewWriter(64),
+	}
+	s.bw.WriteBits(uint64(t0), 32)
+	return s
+}
+
+// Bytes the block as it currently stands (call Finish first for a complete block)
+func (s *Series) Bytes() []byte {
+	s.Lock()
+	defer s.Unlock()
+	return s.bw.Bytes()
+}
+
+// Finish writes the end marker, no more points can be added afterwords
+func (s *Series) Finish() {
+	s.Lock()
+	defer s.Unlock()
+	if !s.finished {
+		finish(s.bw)
+		s.finished = true
+	}
+}
+
+func finish(w *bitstream.Writer) {
+	// '1111' + 0xffffffff + '0'
+	w.WriteBits(0x0f, 4)
+	w.WriteBits(endMarker, 32)
+	w.WriteBit(bitstream.ZeroBit)
+}
+
+// Push add a point to the series
+func (s *Series) Push(t uint32, v float64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.finished {
+		return ErrFinished
+	}
+
+	if !s.started {
+		// first point
+		if t < s.T0 {
+			return ErrTimeOrder
+		}
+		delta := t - s.T0
+		// all ones is reserved for the start of the end marker
+		if delta >= (1<<firstDeltaBits)-1 {
+			return ErrFirstDelta
+		}
+		s.started = true
+		s.t = t
+		s.tDelta = delta
+		s.bw.WriteBits(uint64(delta), firstDeltaBits)
+		s.enc.Encode(s.bw, v)
+		return nil
+	}
+
+	if t < s.t {
+		return ErrTimeOrder
+	}
+
+	tDelta := t - s.t
+	dod := int32(tDelta - s.tDelta)
+
+	switch {
+	case dod == 0:
+		s.bw.WriteBit(bitstream.ZeroBit)
+	case -63 <= dod && dod <= 64:
+		s.bw.WriteBits(0x02, 2) // '10'
+		s.bw.WriteBits(uint64(dod), 7)
+	case -255 <= dod && dod <= 256:
+		s.bw.WriteBits(0x06, 3) // '110'
+		s.bw.WriteBits(uint64(dod), 9)
+	case -2047 <= dod && dod <= 2048:
+		s.bw.WriteBits(0x0e, 4) // '1110'
+		s.bw.WriteBits(uint64(dod), 12)
+	default:
+		s.bw.WriteBits(0x0f, 4) // '1111'
+		s.bw.WriteBits(uint64(dod), 32)
+	}
+
+	s.enc.Encode(s.bw, v)
+
+	s.tDelta = tDelta
+	s.t = t
+	return nil
+}
+
+// Iter an iterator over a snapshot of the series, the series can still be pushed to
+func (s *Series) Iter() *Iter {
+	s.Lock()
+	w := s.bw.Clone()
+	finished := s.finished
+	s.Unlock()
+
+	if !finished {
+		finish(w)
+	}
+	iter, _ := newIterator(w.Reader())
+	return iter
+}
@@ -0,0 +1,111 @@
+package tsz
+
+import (
+	"math"
+	"testing"
+)
+
+type point struct {
+	t uint32
+	v float64
+}
+
+func TestTszRoundTrip(t *testing.T) {
+	t0 := uint32(1500000000)
+	pts := []point{
+		{t0 + 10, 1.0},
+		{t0 + 20, 1.0},
+		{t0 + 30, 1.5},
+		{t0 + 41, -1.5},
+		{t0 + 41, 123123.123},
+		{t0 + 300, 0},
+		{t0 + 5000, math.Inf(1)},
+		{t0 + 100000, math.MaxFloat64},
+		{t0 + 100001, math.SmallestNonzeroFloat64},
+		{t0 + 100002, 2},
+	}
+
+	s := New(t0)
+	for _, p := range pts {
+		if err := s.Push(p.t, p.v); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+
+	// an un-finished series can still be iterated
+	check := func(it *Iter) {
+		i := 0
+		for it.Next() {
+			tt, vv := it.Values()
+			if tt != pts[i].t || vv != pts[i].v {
+				t.Fatalf("point %d mismatch: got (%d, %v) wanted (%d, %v)", i, tt, vv, pts[i].t, pts[i].v)
+			}
+			i++
+		}
+		if it.Err() != nil {
+			t.Fatalf("iter error: %v", it.Err())
+		}
+		if i != len(pts) {
+			t.Fatalf("got %d points wanted %d", i, len(pts))
+		}
+	}
+	check(s.Iter())
+
+	s.Finish()
+	if err := s.Push(t0+200000, 1); err != ErrFinished {
+		t.Fatalf("push after finish should fail")
+	}
+
+	it, err := NewIterator(s.Bytes())
+	if err != nil {
+		t.Fatalf("new iterator failed: %v", err)
+	}
+	if it.T0 != t0 {
+		t.Fatalf("T0 mismatch %d != %d", it.T0, t0)
+	}
+	check(it)
+}
+
+func TestTszEmpty(t *testing.T) {
+	s := New(0)
+	s.Finish()
+	it := s.Iter()
+	if it.Next() {
+		t.Fatalf("empty series should have no points")
+	}
+	if it.Err() != nil {
+		t.Fatalf("iter error: %v", it.Err())
+	}
+}
+
+func TestTszErrors(t *testing.T) {
+	s := New(100)
+	if err := s.Push(99, 1); err != ErrTimeOrder {
+		t.Fatalf("expected ErrTimeOrder got %v", err)
+	}
+	if err := s.Push(100+(1<<firstDeltaBits), 1); err != ErrFirstDelta {
+		t.Fatalf("expected ErrFirstDelta got %v", err)
+	}
+	if err := s.Push(100, 1); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if err := s.Push(99, 1); err != ErrTimeOrder {
+		t.Fatalf("expected ErrTimeOrder got %v", err)
+	}
+}
+
+func FuzzIterator(f *testing.F) {
+	s := New(1500000000)
+	s.Push(1500000010, 1)
+	s.Push(1500000020, 2.5)
+	s.Finish()
+	f.Add(s.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		it, err := NewIterator(data)
+		if err != nil {
+			return
+		}
+		for it.Next() {
+		}
+	})
+}
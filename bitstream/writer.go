@@ -0,0 +1,258 @@
+package bitstream
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Writer a write only bit stream
+//
+// Note: this is not a thread safe implementation, locking should be done externally
+type Writer struct {
+	// the data stream
+	stream []byte
+
+	// how many bits are free in the current (last) byte
+	count uint8
+
+	bitsWritten int
+
+	order BitOrder
+}
+
+// NewWriter new bit writer with an initial capacity of size bytes
+func NewWriter(size int) *Writer {
+	return &Writer{stream: make([]byte, 0, size), count: 0, bitsWritten: 0}
+}
+
+// Reset empty the stream keeping the underlying buffer (and the bit order), anything
+// handed out by Bytes or Reader before hand gets over written by the next writes
+func (b *Writer) Reset() {
+	b.stream = b.stream[:0]
+	b.count = 0
+	b.bitsWritten = 0
+}
+
+// SetOrder set the bit order (MSBFirst by default) for the bits written from now on
+// this should be set before anything is written
+func (b *Writer) SetOrder(o BitOrder) {
+	b.order = o
+}
+
+// Order the current bit order
+func (b *Writer) Order() BitOrder {
+	return b.order
+}
+
+// Len length in Bytes of the stream, a partial last byte counts (not thread safe)
+func (b *Writer) Len() int {
+	return int((b.validBits() + 7) / 8)
+}
+
+// BitLen the exact number of bits in the stream (not thread safe)
+func (b *Writer) BitLen() int64 {
+	return b.validBits()
+}
+
+// Bytes the stream as it stands
+func (b *Writer) Bytes() []byte {
+	return b.stream
+}
+
+// Clone a Writer in to a new one
+func (b *Writer) Clone() *Writer {
+	d := make([]byte, len(b.stream), cap(b.stream))
+	copy(d, b.stream)
+	return &Writer{stream: d, count: b.count, bitsWritten: b.bitsWritten, order: b.order}
+}
+
+// Append the bits of other onto the end of this stream, there is no need for either
+// to be byte aligned, if the bit orders differ the bits keep their sequence (so a
+// single bit read gets the same bits, multi bit values come back reversed)
+func (b *Writer) Append(other *Writer) {
+	b.appendBits(other.Reader())
+}
+
+// appendBits write everything left in r
+func (b *Writer) appendBits(r *Reader) {
+	for left := r.Remaining(); left > 0; left -= 64 {
+		n := 64
+		if left < 64 {
+			n = int(left)
+		}
+		u, _ := r.ReadBits(n)
+		if r.order != b.order {
+			// the first bit read has to be the first one written
+			u = bits.Reverse64(u) >> uint(64-n)
+		}
+		b.WriteBits(u, n)
+	}
+}
+
+// Reader a Reader over the bits written so far, the Reader shares the
+// underlying bytes, so it is only valid until the next write
+func (b *Writer) Reader() *Reader {
+	r := NewReaderBits(b.stream, b.validBits())
+	r.SetOrder(b.order)
+	return r
+}
+
+// number of valid bits in the stream
+func (b *Writer) validBits() int64 {
+	return int64(len(b.stream))*8 - int64(b.count)
+}
+
+// WriteBit write a single bit
+func (b *Writer) WriteBit(bit Bit) {
+
+	if b.count == 0 {
+		b.stream = append(b.stream, 0)
+		b.count = 8
+	}
+
+	i := len(b.stream) - 1
+
+	if bit {
+		if b.order == LSBFirst {
+			b.stream[i] |= 1 << (8 - b.count)
+		} else {
+			b.stream[i] |= 1 << (b.count - 1)
+		}
+	}
+	b.bitsWritten++
+	b.count--
+}
+
+// WriteBytes write a byte slice
+func (b *Writer) WriteBytes(bs []byte) int {
+	c := 0
+	for _, by := range bs {
+		b.WriteByte(by)
+		c++
+	}
+	return c
+}
+
+// WriteByte write a byte to the stream
+func (b *Writer) WriteByte(byt byte) error {
+
+	if b.order == LSBFirst {
+		b.writeBitsLSB(uint64(byt), 8)
+		return nil
+	}
+
+	if b.count == 0 {
+		b.stream = append(b.stream, 0)
+		b.count = 8
+	}
+
+	i := len(b.stream) - 1
+
+	// fill up b.b with b.count bits from byt
+	b.stream[i] |= byt >> (8 - b.count)
+
+	b.stream = append(b.stream, 0)
+	i++
+	b.stream[i] = byt << b.count
+	b.bitsWritten += 8
+	return nil
+}
+
+// WriteBits write nbits (at most 64) to the stream
+//
+// The bits are merged into the stream a 64 bit word at a time, the result is
+// byte for byte (and count for count) what writing them out a byte and then a bit
+// at a time via WriteByte/WriteBit produces
+func (b *Writer) WriteBits(u uint64, nbits int) {
+	if nbits <= 0 {
+		return
+	}
+	if nbits > 64 {
+		nbits = 64
+	}
+	if b.order == LSBFirst {
+		b.writeBitsLSB(u, nbits)
+		return
+	}
+	if nbits < 64 {
+		u &= 1<<uint(nbits) - 1
+	}
+	b.bitsWritten += nbits
+
+	pos := b.validBits()
+	end := pos + int64(nbits)
+
+	// a run of whole bytes leaves an empty byte on the end (count == 8) just
+	// like WriteByte does, anything else leaves a partial (or full) last byte
+	count := uint8(8 - end%8)
+	if nbits%8 != 0 {
+		count %= 8
+	}
+	b.grow(int((end + int64(count)) / 8))
+
+	// top up the current partial byte
+	if off := int(pos & 7); off != 0 {
+		take := 8 - off
+		if take > nbits {
+			take = nbits
+		}
+		nbits -= take
+		b.stream[pos>>3] |= byte(u>>uint(nbits)) << uint(8-off-take)
+		pos += int64(take)
+		u &= 1<<uint(nbits) - 1
+	}
+
+	// the rest is byte aligned, the bytes past the valid bits are all zero so the
+	// left aligned word (zeros below the bits) can just be dropped in
+	if nbits > 0 {
+		w := u << uint(64-nbits)
+		i := int(pos >> 3)
+		full := b.stream[:cap(b.stream)]
+		if i+8 <= len(full) {
+			binary.BigEndian.PutUint64(full[i:], w)
+		} else {
+			for ; nbits > 0; nbits -= 8 {
+				full[i] = byte(w >> 56)
+				w <<= 8
+				i++
+			}
+		}
+	}
+
+	b.count = count
+}
+
+// grow the stream to n bytes, the new bytes are zeroed
+func (b *Writer) grow(n int) {
+	l := len(b.stream)
+	if n <= l {
+		return
+	}
+	if n > cap(b.stream) {
+		b.stream = append(b.stream, make([]byte, n-l)...)
+		return
+	}
+	b.stream = b.stream[:n]
+	for i := l; i < n; i++ {
+		b.stream[i] = 0
+	}
+}
+
+// writeBitsLSB write the low nbits of u, low bit first, filling each byte from the bottom up
+func (b *Writer) writeBitsLSB(u uint64, nbits int) {
+	for nbits > 0 {
+		if b.count == 0 {
+			b.stream = append(b.stream, 0)
+			b.count = 8
+		}
+		take := int(b.count)
+		if take > nbits {
+			take = nbits
+		}
+		b.stream[len(b.stream)-1] |= byte(u&(1<<uint(take)-1)) << (8 - b.count)
+		u >>= uint(take)
+		nbits -= take
+		b.count -= uint8(take)
+		b.bitsWritten += take
+	}
+}
@@ -0,0 +1,127 @@
+package once
+
+import (
+	"errors"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrGoexit the function called runtime.Goexit (t.FailNow and friends) instead of returning
+var ErrGoexit = errors.New("once: function called runtime.Goexit")
+
+// Group a keyed Once, concurrent Do calls for the same key share one run of the
+// function and the result is kept for the key until Forget (or Reset)
+//
+// The zero value is ready to use, and must not be copied after first use
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*groupCall[V]
+
+	// waiting called when a caller is about to wait on a run (for the tests)
+	waiting func()
+}
+
+type groupCall[V any] struct {
+	done chan struct{}
+	v    V
+	err  error
+
+	// share hand an error to the callers waiting on the run, keep keep it like a value
+	share, keep bool
+	// failed an error is kept, set under Group.mu
+	failed bool
+}
+
+// Do the result of fn for key, running it only if there is no result (or run in
+// progress) for key
+//
+// An error goes to the caller whose run failed and is not kept, the callers waiting
+// on that run try again together (one more run, its error they all get). A kept error
+// (see DoShared) is ignored and fn run again. A panic in fn is passed on to its caller
+// and never kept, callers waiting on it get (or retry on) a *PanicError
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (V, error) {
+	return g.do(key, fn, false)
+}
+
+// DoShared Do, but an error (from a run this call starts) is handed to every caller
+// waiting on the run and kept like a value
+func (g *Group[K, V]) DoShared(key K, fn func() (V, error)) (V, error) {
+	return g.do(key, fn, true)
+}
+
+func (g *Group[K, V]) do(key K, fn func() (V, error), share bool) (V, error) {
+	retry := false
+	for {
+		g.mu.Lock()
+		if g.calls == nil {
+			g.calls = make(map[K]*groupCall[V])
+		}
+		if c, ok := g.calls[key]; ok && (share || !c.failed) {
+			g.mu.Unlock()
+			if g.waiting != nil {
+				g.waiting()
+			}
+			<-c.done
+			if c.err != nil && !share && !c.share && !retry {
+				retry = true
+				continue
+			}
+			return c.v, c.err
+		}
+		// a retry hands its error to the others retrying with it
+		c := &groupCall[V]{done: make(chan struct{}), share: share || retry, keep: share}
+		g.calls[key] = c
+		g.mu.Unlock()
+
+		g.run(key, c, fn)
+		return c.v, c.err
+	}
+}
+
+func (g *Group[K, V]) run(key K, c *groupCall[V], fn func() (V, error)) {
+	panicked := true
+	defer func() {
+		if !panicked {
+			return
+		}
+		r := recover()
+		if r == nil {
+			c.err = ErrGoexit
+			g.finish(key, c, false)
+			return
+		}
+		c.err = &PanicError{Value: r, Stack: debug.Stack()}
+		g.finish(key, c, false)
+		panic(r)
+	}()
+	c.v, c.err = fn()
+	panicked = false
+	g.finish(key, c, c.err == nil || c.keep)
+}
+
+// finish drop the call unless it is to be kept and wake up the waiters
+func (g *Group[K, V]) finish(key K, c *groupCall[V], keep bool) {
+	g.mu.Lock()
+	if keep {
+		c.failed = c.err != nil
+	} else if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+	close(c.done)
+}
+
+// Forget drop the result for key, the next Do runs the function again (callers
+// already waiting on a run still get its result)
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.calls, key)
+}
+
+// Reset drop the results for every key
+func (g *Group[K, V]) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = nil
+}
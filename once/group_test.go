@@ -0,0 +1,150 @@
+package once
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// waitCounter a Group hook counting the callers waiting on a run
+type waitCounter chan struct{}
+
+func (w waitCounter) hook() {
+	w <- struct{}{}
+}
+
+// for block until n callers have started waiting
+func (w waitCounter) wait(n int) {
+	for i := 0; i < n; i++ {
+		<-w
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var g Group[string, int]
+	waits := make(waitCounter, 64)
+	g.waiting = waits.hook
+	var calls int32
+	fn := func() (int, error) {
+		if atomic.LoadInt32(&calls) == 0 {
+			// the first run is not done until everyone is waiting on it
+			waits.wait(19)
+		}
+		return int(atomic.AddInt32(&calls, 1)), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := g.Do("a", fn); err != nil || v != 1 {
+				t.Errorf("got %d %v wanted 1", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := g.Do("a", fn); v != 1 || calls != 1 {
+		t.Fatalf("result should be kept, got %d after %d calls", v, calls)
+	}
+	if v, _ := g.Do("b", fn); v != 2 {
+		t.Fatalf("keys should not share results, got %d", v)
+	}
+	g.Forget("a")
+	if v, _ := g.Do("a", fn); v != 3 {
+		t.Fatalf("forget should rerun, got %d", v)
+	}
+	g.Reset()
+	if v, _ := g.Do("b", fn); v != 4 {
+		t.Fatalf("reset should rerun, got %d", v)
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	boom := errors.New("boom")
+	for _, share := range []bool{false, true} {
+		var g Group[int, int]
+		waits := make(waitCounter, 64)
+		g.waiting = waits.hook
+		do := g.Do
+		if share {
+			do = g.DoShared
+		}
+		// the callers waiting on each run: the other 4 on the first, then (not
+		// shared) the 3 left on the retry
+		need := []int{4, 3}
+		var calls int32
+		fn := func() (int, error) {
+			if n := atomic.LoadInt32(&calls); int(n) < len(need) {
+				waits.wait(need[n])
+			}
+			atomic.AddInt32(&calls, 1)
+			return 0, boom
+		}
+		if share {
+			need = need[:1]
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := do(1, fn); err != boom {
+					t.Errorf("expected the error got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if share && calls != 1 {
+			t.Fatalf("shared errors should go to every waiter, ran %d times", calls)
+		}
+		if !share && calls != 2 {
+			t.Fatalf("the waiters should share one retry, ran %d times", calls)
+		}
+
+		before := calls
+		do(1, fn)
+		if share && calls != before {
+			t.Fatalf("shared errors should be kept, ran %d times", calls)
+		}
+		if !share && calls != before+1 {
+			t.Fatalf("errors should not be kept, ran %d times", calls)
+		}
+	}
+}
+
+func TestGroupSharedThenDo(t *testing.T) {
+	var g Group[string, int]
+	boom := errors.New("boom")
+	if _, err := g.DoShared("a", func() (int, error) { return 0, boom }); err != boom {
+		t.Fatalf("expected the error got %v", err)
+	}
+	if _, err := g.DoShared("a", func() (int, error) { return 1, nil }); err != boom {
+		t.Fatalf("the error should be kept got %v", err)
+	}
+	if v, err := g.Do("a", func() (int, error) { return 2, nil }); err != nil || v != 2 {
+		t.Fatalf("Do should not take a kept error, got %d %v", v, err)
+	}
+	if v, _ := g.DoShared("a", func() (int, error) { return 3, nil }); v != 2 {
+		t.Fatalf("the value should be kept got %d", v)
+	}
+}
+
+func TestGroupPanic(t *testing.T) {
+	var g Group[string, string]
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic got %v", r)
+			}
+		}()
+		g.Do("a", func() (string, error) { panic("boom") })
+	}()
+	if v, err := g.Do("a", func() (string, error) { return "ok", nil }); err != nil || v != "ok" {
+		t.Fatalf("a panic should not be kept, got %q %v", v, err)
+	}
+}
@@ -0,0 +1,230 @@
+package once
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+)
+
+// State where a Lifecycle is at
+type State int32
+
+const (
+	// Stopped not running (the starting state)
+	Stopped State = iota
+	// Starting the start function is running
+	Starting
+	// Running started ok
+	Running
+	// Stopping the stop function is running
+	Stopping
+	// Failed the start function returned an error, see Lifecycle.Err
+	Failed
+)
+
+// String the state name
+func (s State) String() string {
+	switch s {
+	case Stopped:
+		return "stopped"
+	case Starting:
+		return "starting"
+	case Running:
+		return "running"
+	case Stopping:
+		return "stopping"
+	case Failed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Lifecycle a start/stop state machine
+//
+//	Stopped/Failed -> Starting -> Running/Failed
+//	Running -> Stopping -> Stopped/Running
+//
+// A failed stop goes back to Running (with Err set) as whatever it was is still going,
+// so it can be stopped again but not started, only a failed start can be started again.
+// Only one Start or Stop runs at a time, the others wait for it to finish and then
+// carry on from the state it left. A start or stop function (or hook) that panics
+// fails the same way with a *PanicError (and the panic carries on). The zero value is
+// a Stopped Lifecycle
+type Lifecycle struct {
+	mu    sync.Mutex
+	state State
+	err   error
+
+	// closed (and dropped) on every transition
+	change chan struct{}
+
+	hooks      []func(from, to State)
+	pending    []transition
+	delivering bool
+}
+
+type transition struct {
+	from, to State
+}
+
+// State the current state
+func (l *Lifecycle) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// Err the error from the last start (Failed) or stop (still Running) that failed, nil
+// after a success
+func (l *Lifecycle) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// OnTransition add a hook run on every state change, hooks are run one at a time in
+// the order the transitions happened (not always before Start/Stop returns when
+// they are called from many go routines)
+func (l *Lifecycle) OnTransition(f func(from, to State)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, f)
+}
+
+// Wait until the Lifecycle is in state s, or ctx is done
+func (l *Lifecycle) Wait(ctx context.Context, s State) error {
+	for {
+		l.mu.Lock()
+		if l.state == s {
+			l.mu.Unlock()
+			return nil
+		}
+		ch := l.changed()
+		l.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Start run f if not Running (or about to be), Running on success, Failed (and the
+// error returned) otherwise
+func (l *Lifecycle) Start(ctx context.Context, f func(context.Context) error) error {
+	return l.run(ctx, f, Starting, Running, Failed)
+}
+
+// Stop run f if Running (or about to be), Stopped on success, back to Running (and the
+// error returned) otherwise, a failed start has nothing to stop and is left Failed
+func (l *Lifecycle) Stop(ctx context.Context, f func(context.Context) error) error {
+	return l.run(ctx, f, Stopping, Stopped, Running)
+}
+
+// run move through via to done, or to failed if f fails
+func (l *Lifecycle) run(ctx context.Context, f func(context.Context) error, via, done, failed State) error {
+	for {
+		l.mu.Lock()
+		switch l.state {
+		case done:
+			l.mu.Unlock()
+			return nil
+		case Failed:
+			if via == Stopping {
+				// a failed start, nothing to stop
+				l.mu.Unlock()
+				return nil
+			}
+		case Starting, Stopping:
+			// wait for the one in progress
+			ch := l.changed()
+			l.mu.Unlock()
+			select {
+			case <-ch:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			l.mu.Unlock()
+			return err
+		}
+		break
+	}
+
+	// l.mu is held
+	panicked := true
+	defer func() {
+		if !panicked {
+			return
+		}
+		// f (or a hook) panicked, do not leave it stuck in via
+		r := recover()
+		var perr error = ErrGoexit
+		if r != nil {
+			perr = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		l.mu.Lock()
+		l.set(failed, perr)
+		if r != nil {
+			panic(r)
+		}
+	}()
+	l.set(via, nil)
+	err := f(ctx)
+	panicked = false
+	l.mu.Lock()
+	if err != nil {
+		l.set(failed, err)
+	} else {
+		l.set(done, nil)
+	}
+	return err
+}
+
+// changed the channel closed on the next transition, l.mu must be held
+func (l *Lifecycle) changed() chan struct{} {
+	if l.change == nil {
+		l.change = make(chan struct{})
+	}
+	return l.change
+}
+
+// set move to state to, l.mu must be held and is released
+func (l *Lifecycle) set(to State, err error) {
+	from := l.state
+	l.state, l.err = to, err
+	if l.change != nil {
+		close(l.change)
+		l.change = nil
+	}
+	l.pending = append(l.pending, transition{from, to})
+	if l.delivering {
+		l.mu.Unlock()
+		return
+	}
+
+	// deliver everything pending, including what other go routines add meanwhile
+	l.delivering = true
+	defer func() {
+		// even if a hook panics, the rest are delivered on the next transition
+		l.delivering = false
+		l.mu.Unlock()
+	}()
+	for len(l.pending) > 0 {
+		t := l.pending[0]
+		l.pending = l.pending[1:]
+		l.deliver(l.hooks, t)
+	}
+}
+
+// deliver run the hooks for t, l.mu must be held and is held again after
+func (l *Lifecycle) deliver(hooks []func(from, to State), t transition) {
+	l.mu.Unlock()
+	defer l.mu.Lock()
+	for _, h := range hooks {
+		h(t.from, t.to)
+	}
+}
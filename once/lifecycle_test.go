@@ -0,0 +1,235 @@
+package once
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	var l Lifecycle
+	ctx := context.Background()
+	var seen []transition
+	l.OnTransition(func(from, to State) {
+		seen = append(seen, transition{from, to})
+		if l.State() != to {
+			t.Errorf("hook for %v ran in state %v", to, l.State())
+		}
+	})
+
+	if l.State() != Stopped {
+		t.Fatalf("zero Lifecycle should be stopped got %v", l.State())
+	}
+	boom := errors.New("boom")
+	if err := l.Start(ctx, func(context.Context) error { return boom }); err != boom {
+		t.Fatalf("expected the start error got %v", err)
+	}
+	if l.State() != Failed || l.Err() != boom {
+		t.Fatalf("expected failed with the error got %v %v", l.State(), l.Err())
+	}
+	if err := l.Start(ctx, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if l.State() != Running || l.Err() != nil {
+		t.Fatalf("expected running got %v %v", l.State(), l.Err())
+	}
+	ran := false
+	l.Start(ctx, func(context.Context) error { ran = true; return nil })
+	if ran {
+		t.Fatalf("started while running")
+	}
+	if err := l.Stop(ctx, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	l.Stop(ctx, func(context.Context) error { ran = true; return nil })
+	if ran || l.State() != Stopped {
+		t.Fatalf("stopped while stopped")
+	}
+
+	want := []transition{
+		{Stopped, Starting}, {Starting, Failed},
+		{Failed, Starting}, {Starting, Running},
+		{Running, Stopping}, {Stopping, Stopped},
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("got transitions %v wanted %v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("got transitions %v wanted %v", seen, want)
+		}
+	}
+}
+
+func TestLifecycleWait(t *testing.T) {
+	var l Lifecycle
+	release := make(chan struct{})
+	go l.Start(context.Background(), func(context.Context) error {
+		<-release
+		return nil
+	})
+	if err := l.Wait(context.Background(), Starting); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	// a concurrent start waits (or gives up)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Start(ctx, func(context.Context) error { return nil }); err != context.DeadlineExceeded {
+		t.Fatalf("expected the context error got %v", err)
+	}
+	if err := l.Wait(ctx, Running); err != context.DeadlineExceeded {
+		t.Fatalf("expected the context error got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Wait(context.Background(), Running); err != nil {
+				t.Errorf("wait failed: %v", err)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if s := l.State(); s != Running {
+		t.Fatalf("expected running got %v", s)
+	}
+	if Failed.String() != "failed" || State(99).String() != "unknown" {
+		t.Fatalf("bad state names")
+	}
+}
+
+func TestLifecycleConcurrent(t *testing.T) {
+	var l Lifecycle
+	var mu sync.Mutex
+	var seen []transition
+	l.OnTransition(func(from, to State) {
+		mu.Lock()
+		seen = append(seen, transition{from, to})
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f := func(context.Context) error { return nil }
+			if i%2 == 0 {
+				l.Start(context.Background(), f)
+			} else {
+				l.Stop(context.Background(), f)
+			}
+		}(i)
+	}
+	wg.Wait()
+	l.Stop(context.Background(), func(context.Context) error { return nil })
+
+	// every transition starts where the last one ended
+	mu.Lock()
+	defer mu.Unlock()
+	at := Stopped
+	for _, tr := range seen {
+		if tr.from != at {
+			t.Fatalf("transition %v -> %v from %v", tr.from, tr.to, at)
+		}
+		at = tr.to
+	}
+	if at != Stopped {
+		t.Fatalf("ended in %v", at)
+	}
+}
+
+func TestLifecyclePanic(t *testing.T) {
+	var l Lifecycle
+	ctx := context.Background()
+	mustPanic := func(f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected a panic")
+			}
+		}()
+		f()
+	}
+
+	// a hook that panics once, the next transitions are still delivered
+	var seen []transition
+	l.OnTransition(func(from, to State) {
+		seen = append(seen, transition{from, to})
+		if len(seen) == 1 {
+			panic("hook")
+		}
+	})
+	mustPanic(func() { l.Start(ctx, func(context.Context) error { return nil }) })
+	if st := l.State(); st != Failed {
+		t.Fatalf("the hook panicked on the way to starting, got %v", st)
+	}
+
+	// the start function panics
+	mustPanic(func() {
+		l.Start(ctx, func(context.Context) error { panic("start") })
+	})
+	var pe *PanicError
+	if l.State() != Failed || !errors.As(l.Err(), &pe) || pe.Value != "start" {
+		t.Fatalf("expected failed with the panic got %v %v", l.State(), l.Err())
+	}
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := l.Wait(wctx, Failed); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if err := l.Start(ctx, func(context.Context) error { return nil }); err != nil || l.State() != Running {
+		t.Fatalf("restart after the panic: %v %v", err, l.State())
+	}
+
+	if len(seen) < 4 || seen[len(seen)-1] != (transition{Starting, Running}) {
+		t.Fatalf("hooks stopped being delivered %v", seen)
+	}
+}
+
+func TestLifecycleFailures(t *testing.T) {
+	var l Lifecycle
+	ctx := context.Background()
+	boom := errors.New("boom")
+	starts, stops := 0, 0
+	start := func(err error) func(context.Context) error {
+		return func(context.Context) error { starts++; return err }
+	}
+	stop := func(err error) func(context.Context) error {
+		return func(context.Context) error { stops++; return err }
+	}
+
+	// a failed start has nothing to stop, but can be started again
+	l.Start(ctx, start(boom))
+	if err := l.Stop(ctx, stop(nil)); err != nil || stops != 0 || l.State() != Failed || l.Err() != boom {
+		t.Fatalf("stopped a failed start: %v, %d stops, %v %v", err, stops, l.State(), l.Err())
+	}
+	if err := l.Start(ctx, start(nil)); err != nil || starts != 2 || l.State() != Running {
+		t.Fatalf("restart after a failed start: %v, %d starts, %v", err, starts, l.State())
+	}
+
+	// a failed stop is still running, it can be stopped again but not started
+	if err := l.Stop(ctx, stop(boom)); err != boom || l.State() != Running || l.Err() != boom {
+		t.Fatalf("failed stop: %v %v %v", err, l.State(), l.Err())
+	}
+	if err := l.Start(ctx, start(nil)); err != nil || starts != 2 {
+		t.Fatalf("started after a failed stop: %v, %d starts", err, starts)
+	}
+	func() {
+		defer func() { recover() }()
+		l.Stop(ctx, func(context.Context) error { panic("stop") })
+	}()
+	var pe *PanicError
+	if l.State() != Running || !errors.As(l.Err(), &pe) {
+		t.Fatalf("panicked stop: %v %v", l.State(), l.Err())
+	}
+	if err := l.Stop(ctx, stop(nil)); err != nil || stops != 2 || l.State() != Stopped || l.Err() != nil {
+		t.Fatalf("stop retry: %v, %d stops, %v %v", err, stops, l.State(), l.Err())
+	}
+}
@@ -5,6 +5,7 @@
 package once
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 )
@@ -36,24 +37,104 @@ func (o *Once) Reset() {
 	atomic.StoreUint32(&o.done, 0)
 }
 
+// ctxMutex a mutex that can give up waiting when a context is done, the zero value is unlocked
+type ctxMutex struct {
+	init sync.Once
+	ch   chan struct{}
+}
+
+func (m *ctxMutex) sem() chan struct{} {
+	m.init.Do(func() {
+		m.ch = make(chan struct{}, 1)
+	})
+	return m.ch
+}
+
+// Lock wait for the lock
+func (m *ctxMutex) Lock() {
+	m.sem() <- struct{}{}
+}
+
+// LockContext wait for the lock or for ctx to be done (the lock is not held then)
+func (m *ctxMutex) LockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case m.sem() <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unlock release the lock
+func (m *ctxMutex) Unlock() {
+	<-m.sem()
+}
+
 // StartStop start/stop once in a group
+//
+// Start runs only if not already started, Stop runs only if not already stopped
+// (a fresh StartStop is neither), the two never run at the same time
 type StartStop struct {
-	start Once
-	stop  Once
+	mu      ctxMutex
+	started bool
+	stopped bool
 }
 
 // Start a function just once and rest the Stop caller, so we can stop things if needed
 func (o *StartStop) Start(f func()) {
-	o.start.Do(func() {
-		f()
-		o.stop.Reset()
-	})
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.started {
+		return
+	}
+	f()
+	o.started, o.stopped = true, false
 }
 
 // Stop run a stop function only once and reset the start blocker
 func (o *StartStop) Stop(f func()) {
-	o.stop.Do(func() {
-		f()
-		o.start.Reset()
-	})
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stopped {
+		return
+	}
+	f()
+	o.started, o.stopped = false, true
+}
+
+// StartE like Start but f can fail, on an error nothing changes (a later StartE will
+// try again) and the error is returned, if another Start/Stop is running StartE waits
+// for it or returns ctx.Err() if ctx is done first
+func (o *StartStop) StartE(ctx context.Context, f func(context.Context) error) error {
+	if err := o.mu.LockContext(ctx); err != nil {
+		return err
+	}
+	defer o.mu.Unlock()
+	if o.started {
+		return nil
+	}
+	if err := f(ctx); err != nil {
+		return err
+	}
+	o.started, o.stopped = true, false
+	return nil
+}
+
+// StopE like Stop but f can fail, see StartE
+func (o *StartStop) StopE(ctx context.Context, f func(context.Context) error) error {
+	if err := o.mu.LockContext(ctx); err != nil {
+		return err
+	}
+	defer o.mu.Unlock()
+	if o.stopped {
+		return nil
+	}
+	if err := f(ctx); err != nil {
+		return err
+	}
+	o.started, o.stopped = false, true
+	return nil
 }
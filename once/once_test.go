@@ -0,0 +1,89 @@
+package once
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartStop(t *testing.T) {
+	var o StartStop
+	starts, stops := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.Start(func() { starts++ })
+		}()
+	}
+	wg.Wait()
+	o.Stop(func() { stops++ })
+	o.Stop(func() { stops++ })
+	o.Start(func() { starts++ })
+	if starts != 2 || stops != 1 {
+		t.Fatalf("got %d starts %d stops wanted 2 and 1", starts, stops)
+	}
+}
+
+func TestStartStopE(t *testing.T) {
+	var o StartStop
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	if err := o.StartE(ctx, func(context.Context) error { return boom }); err != boom {
+		t.Fatalf("expected the start error got %v", err)
+	}
+	ran := false
+	if err := o.StartE(ctx, func(context.Context) error { ran = true; return nil }); err != nil || !ran {
+		t.Fatalf("a failed start should not count as started: %v", err)
+	}
+	ran = false
+	if err := o.StartE(ctx, func(context.Context) error { ran = true; return nil }); err != nil || ran {
+		t.Fatalf("started twice: %v", err)
+	}
+
+	if err := o.StopE(ctx, func(context.Context) error { return boom }); err != boom {
+		t.Fatalf("expected the stop error got %v", err)
+	}
+	if err := o.StartE(ctx, func(context.Context) error { ran = true; return nil }); err != nil || ran {
+		t.Fatalf("a failed stop should leave it started: %v", err)
+	}
+	if err := o.StopE(ctx, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	if err := o.StartE(ctx, func(context.Context) error { ran = true; return nil }); err != nil || !ran {
+		t.Fatalf("start after stop did not run: %v", err)
+	}
+}
+
+func TestStartEContext(t *testing.T) {
+	var o StartStop
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go o.StartE(context.Background(), func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ran := false
+	if err := o.StartE(ctx, func(context.Context) error { ran = true; return nil }); err != context.DeadlineExceeded || ran {
+		t.Fatalf("expected the context error while waiting got %v", err)
+	}
+
+	close(release)
+	if err := o.StopE(context.Background(), func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+
+	cancel()
+	if err := o.StartE(ctx, func(context.Context) error { ran = true; return nil }); err == nil || ran {
+		t.Fatalf("a done context should not start: %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package once
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManyRestarts the restart limit was hit and the RestartSupervisor gave up
+var ErrTooManyRestarts = errors.New("once: too many restarts")
+
+// errExited a child returned nil before it was stopped
+var errExited = errors.New("once: exited before stop")
+
+// ErrRunning children can not be added while the RestartSupervisor is running
+var ErrRunning = errors.New("once: supervisor is running")
+
+// Runner a long running component, Run should block until ctx is done, returning
+// (or panicking) before then counts as a crash
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+// RunnerFunc a function as a Runner
+type RunnerFunc func(ctx context.Context) error
+
+// Run call f
+func (f RunnerFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Strategy what gets restarted when a child crashes
+type Strategy int
+
+const (
+	// OneForOne restart just the child that crashed
+	OneForOne Strategy = iota
+	// OneForAll stop all the children and restart them together
+	OneForAll
+)
+
+// Backoff exponential backoff with jitter, zero fields get the defaults
+type Backoff struct {
+	// Initial the first delay (100ms)
+	Initial time.Duration
+	// Max the largest delay (30s), a run that lasts this long resets the backoff
+	Max time.Duration
+	// Multiplier the growth per attempt (2)
+	Multiplier float64
+	// Jitter the delay is randomly moved up to this fraction either way (0.2), < 0 is none
+	Jitter float64
+}
+
+func (b Backoff) withDefaults() Backoff {
+	if b.Initial <= 0 {
+		b.Initial = 100 * time.Millisecond
+	}
+	if b.Max <= 0 {
+		b.Max = 30 * time.Second
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = 2
+	}
+	if b.Jitter == 0 {
+		b.Jitter = 0.2
+	}
+	return b
+}
+
+// Delay the delay before restart attempt (0 is the first)
+func (b Backoff) Delay(attempt int) time.Duration {
+	b = b.withDefaults()
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	if d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d *= 1 + b.Jitter*(2*rand.Float64()-1)
+		if d > float64(b.Max) {
+			d = float64(b.Max)
+		}
+	}
+	return time.Duration(d)
+}
+
+// RestartSupervisor keeps a set of long running children going, restarting them
+// (with backoff) when they crash, until Stop or the restart limit is hit
+//
+// Starting and stopping goes through a StartStop, a Stop cancels the children and
+// waits for them, nothing is restarted after it (even one that crashed while stopping).
+// Set the fields before Start, the zero value is a OneForOne supervisor that never gives up
+type RestartSupervisor struct {
+	Strategy Strategy
+	Backoff  Backoff
+
+	// MaxRestarts more then this many restarts within Window and the supervisor gives
+	// up (stops all the children, see Err and Done), 0 is no limit
+	MaxRestarts int
+	// Window the time MaxRestarts is counted over, 0 is forever
+	Window time.Duration
+
+	ss StartStop
+
+	mu       sync.Mutex
+	children []*restartChild
+	restarts []time.Time
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
+}
+
+type restartChild struct {
+	name     string
+	r        Runner
+	restarts int64
+}
+
+// Add a child, children can only be added while stopped
+func (s *RestartSupervisor) Add(name string, r Runner) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return ErrRunning
+	}
+	for _, c := range s.children {
+		if c.name == name {
+			return &ComponentError{Name: name, Err: ErrDuplicate}
+		}
+	}
+	s.children = append(s.children, &restartChild{name: name, r: r})
+	return nil
+}
+
+// Restarts the number of times the named child has been restarted (since it was added)
+func (s *RestartSupervisor) Restarts(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.children {
+		if c.name == name {
+			return int(atomic.LoadInt64(&c.restarts))
+		}
+	}
+	return 0
+}
+
+// Done closed once all the children have finished after a Stop, or after the
+// supervisor gave up, nil if it was never started
+func (s *RestartSupervisor) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// Err ErrTooManyRestarts (wrapping the last crash) if the supervisor gave up
+func (s *RestartSupervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Start run all the children (once, until Stop, after giving up Stop has to be
+// called before it can be started again)
+func (s *RestartSupervisor) Start() {
+	s.ss.Start(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		s.mu.Lock()
+		s.cancel, s.done, s.err, s.restarts = cancel, done, nil, nil
+		children := append([]*restartChild{}, s.children...)
+		s.mu.Unlock()
+
+		go func() {
+			defer close(done)
+			if s.Strategy == OneForAll {
+				s.runAll(ctx, children)
+			} else {
+				s.runEach(ctx, children)
+			}
+		}()
+	})
+}
+
+// Stop cancel all the children and wait for them to finish
+func (s *RestartSupervisor) Stop() {
+	s.ss.Stop(func() {
+		s.mu.Lock()
+		cancel, done := s.cancel, s.done
+		s.cancel = nil
+		s.mu.Unlock()
+		if cancel == nil {
+			return
+		}
+		cancel()
+		<-done
+	})
+}
+
+func (s *RestartSupervisor) runEach(ctx context.Context, children []*restartChild) {
+	var wg sync.WaitGroup
+	for _, c := range children {
+		wg.Add(1)
+		go func(c *restartChild) {
+			defer wg.Done()
+			attempt := 0
+			for {
+				started := time.Now()
+				err := runChild(ctx, c.r)
+				if !s.restart(ctx, started, &attempt, c.name, err) {
+					return
+				}
+				atomic.AddInt64(&c.restarts, 1)
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (s *RestartSupervisor) runAll(ctx context.Context, children []*restartChild) {
+	if len(children) == 0 {
+		return
+	}
+	type crash struct {
+		name string
+		err  error
+	}
+	attempt := 0
+	for {
+		started := time.Now()
+		gctx, gcancel := context.WithCancel(ctx)
+		crashes := make(chan crash, len(children))
+		var wg sync.WaitGroup
+		for _, c := range children {
+			wg.Add(1)
+			go func(c *restartChild) {
+				defer wg.Done()
+				crashes <- crash{c.name, runChild(gctx, c.r)}
+			}(c)
+		}
+		first := <-crashes
+		gcancel()
+		wg.Wait()
+
+		if !s.restart(ctx, started, &attempt, first.name, first.err) {
+			return
+		}
+		for _, c := range children {
+			atomic.AddInt64(&c.restarts, 1)
+		}
+	}
+}
+
+// restart decide if a crashed child (or all of them) should be restarted, and wait
+// out the backoff, false if not (stopped or too many restarts)
+func (s *RestartSupervisor) restart(ctx context.Context, started time.Time, attempt *int, name string, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	b := s.Backoff.withDefaults()
+	if time.Since(started) >= b.Max {
+		// it was up for a good while, start the backoff over
+		*attempt = 0
+	}
+
+	s.mu.Lock()
+	if s.MaxRestarts > 0 && s.record(time.Now()) > s.MaxRestarts {
+		if s.err == nil {
+			if err == nil {
+				err = errExited
+			}
+			s.err = fmt.Errorf("%w: %s: %w", ErrTooManyRestarts, name, err)
+		}
+		cancel := s.cancel
+		s.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		return false
+	}
+	s.mu.Unlock()
+
+	t := time.NewTimer(b.Delay(*attempt))
+	defer t.Stop()
+	*attempt++
+	select {
+	case <-t.C:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// record add a restart at now, dropping the ones that no longer count, the number
+// of restarts that do, s.mu must be held
+func (s *RestartSupervisor) record(now time.Time) int {
+	if s.Window > 0 {
+		keep := s.restarts[:0]
+		for _, t := range s.restarts {
+			if now.Sub(t) < s.Window {
+				keep = append(keep, t)
+			}
+		}
+		s.restarts = keep
+	}
+	s.restarts = append(s.restarts, now)
+	if s.Window <= 0 && len(s.restarts) > s.MaxRestarts+1 {
+		// only whether there are more than MaxRestarts matters
+		s.restarts = append(s.restarts[:0], s.restarts[len(s.restarts)-s.MaxRestarts-1:]...)
+	}
+	return len(s.restarts)
+}
+
+// runChild run r, a panic comes back as a *PanicError
+func runChild(ctx context.Context, r Runner) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	return r.Run(ctx)
+}
@@ -0,0 +1,181 @@
+package once
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastBackoff = Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Jitter: -1}
+
+// crasher crashes the first n runs then runs until stopped
+func crasher(n int32, runs *int32) Runner {
+	return RunnerFunc(func(ctx context.Context) error {
+		if atomic.AddInt32(runs, 1) <= n {
+			return errors.New("crash")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+}
+
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRestartOneForOne(t *testing.T) {
+	var aRuns, bRuns int32
+	s := &RestartSupervisor{Backoff: fastBackoff}
+	s.Add("a", crasher(3, &aRuns))
+	s.Add("b", crasher(0, &bRuns))
+	if err := s.Add("a", crasher(0, &aRuns)); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate got %v", err)
+	}
+
+	s.Start()
+	waitFor(t, "a to restart", func() bool { return atomic.LoadInt32(&aRuns) == 4 })
+	if err := s.Add("c", crasher(0, &bRuns)); err != ErrRunning {
+		t.Fatalf("expected ErrRunning got %v", err)
+	}
+	s.Stop()
+
+	if s.Restarts("a") != 3 || s.Restarts("b") != 0 || bRuns != 1 {
+		t.Fatalf("got restarts a=%d b=%d (b ran %d times)", s.Restarts("a"), s.Restarts("b"), bRuns)
+	}
+	select {
+	case <-s.Done():
+	default:
+		t.Fatalf("stop should wait for the children")
+	}
+	if s.Err() != nil {
+		t.Fatalf("unexpected error %v", s.Err())
+	}
+}
+
+func TestRestartOneForAll(t *testing.T) {
+	var aRuns, bRuns int32
+	s := &RestartSupervisor{Strategy: OneForAll, Backoff: fastBackoff}
+	s.Add("a", crasher(1, &aRuns))
+	s.Add("b", crasher(0, &bRuns))
+
+	s.Start()
+	waitFor(t, "both to restart", func() bool {
+		return atomic.LoadInt32(&aRuns) == 2 && atomic.LoadInt32(&bRuns) == 2
+	})
+	s.Stop()
+	if s.Restarts("a") != 1 || s.Restarts("b") != 1 {
+		t.Fatalf("got restarts a=%d b=%d wanted 1 each", s.Restarts("a"), s.Restarts("b"))
+	}
+}
+
+func TestRestartGiveUp(t *testing.T) {
+	var runs int32
+	s := &RestartSupervisor{Backoff: fastBackoff, MaxRestarts: 3, Window: time.Minute}
+	s.Add("a", RunnerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		panic("boom")
+	}))
+	s.Start()
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("supervisor did not give up")
+	}
+	var pe *PanicError
+	if err := s.Err(); !errors.Is(err, ErrTooManyRestarts) || !errors.As(err, &pe) {
+		t.Fatalf("expected ErrTooManyRestarts with the panic got %v", err)
+	}
+	if runs != 4 || s.Restarts("a") != 3 {
+		t.Fatalf("ran %d times with %d restarts", runs, s.Restarts("a"))
+	}
+
+	// stop then start again
+	s.Stop()
+	s.Start()
+	waitFor(t, "a to run again", func() bool { return atomic.LoadInt32(&runs) > 4 })
+	s.Stop()
+}
+
+func TestRestartStopWins(t *testing.T) {
+	var runs int32
+	crashed := make(chan struct{})
+	s := &RestartSupervisor{Backoff: Backoff{Initial: time.Hour, Max: time.Hour}}
+	s.Add("a", RunnerFunc(func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			close(crashed)
+		}
+		return errors.New("crash")
+	}))
+	s.Start()
+	<-crashed
+
+	// the child is waiting out an hour of backoff, stop should not
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("stop waited on the backoff")
+	}
+	if runs != 1 {
+		t.Fatalf("restarted after stop, ran %d times", runs)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: 10 * time.Millisecond, Max: time.Second, Jitter: -1}
+	for i, want := range []time.Duration{10, 20, 40, 80} {
+		if d := b.Delay(i); d != want*time.Millisecond {
+			t.Fatalf("attempt %d: got %v wanted %v", i, d, want*time.Millisecond)
+		}
+	}
+	if d := b.Delay(100); d != time.Second {
+		t.Fatalf("delay should be capped got %v", d)
+	}
+
+	var zero Backoff
+	for i := 0; i < 100; i++ {
+		if d := zero.Delay(0); d < 80*time.Millisecond || d > 120*time.Millisecond {
+			t.Fatalf("default jittered delay %v out of range", d)
+		}
+	}
+}
+
+func TestRestartBounded(t *testing.T) {
+	var runs int32
+	s := &RestartSupervisor{Backoff: Backoff{Initial: time.Microsecond, Max: time.Microsecond, Jitter: -1}}
+	s.Add("a", crasher(200, &runs))
+	s.Start()
+	waitFor(t, "a to settle", func() bool { return atomic.LoadInt32(&runs) == 201 })
+	s.Stop()
+
+	s.mu.Lock()
+	n := len(s.restarts)
+	s.mu.Unlock()
+	if n != 0 || s.Restarts("a") != 200 {
+		t.Fatalf("kept %d restart times for %d restarts with no limit", n, s.Restarts("a"))
+	}
+
+	// no window, only the last MaxRestarts (and the one over) are needed
+	s = &RestartSupervisor{MaxRestarts: 3}
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		got := s.record(time.Now())
+		s.mu.Unlock()
+		if want := min(i+1, 4); got != want || len(s.restarts) != want {
+			t.Fatalf("record %d: got %d kept %d wanted %d", i, got, len(s.restarts), want)
+		}
+	}
+}
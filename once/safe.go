@@ -0,0 +1,111 @@
+package once
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// PanicError a panic recovered from a SafeOnce function
+type PanicError struct {
+	// Value what was passed to panic
+	Value interface{}
+	// Stack the stack of the panicking go routine
+	Stack []byte
+}
+
+// Error the error string
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("once: function panicked: %v", e.Value)
+}
+
+// Unwrap the panic value if it was an error
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+const (
+	safeNotRun uint32 = iota
+	safeDone
+	safeFailed
+)
+
+// SafeOnce like Once but a panic in f does not count as done, the first panic is
+// recorded and handed to every caller (re-panicked by Do, returned by DoErr) until Reset
+type SafeOnce struct {
+	m     sync.Mutex
+	state uint32
+	err   *PanicError
+}
+
+// Do do the function if it has not been done, a panic (this time or from before)
+// is raised as a *PanicError
+func (o *SafeOnce) Do(f func()) {
+	if err := o.DoErr(f); err != nil {
+		panic(err)
+	}
+}
+
+// DoErr do the function if it has not been done, a panic (this time or from before)
+// is returned as a *PanicError
+func (o *SafeOnce) DoErr(f func()) error {
+	switch atomic.LoadUint32(&o.state) {
+	case safeDone:
+		return nil
+	case safeFailed:
+		return o.Err()
+	}
+
+	o.m.Lock()
+	defer o.m.Unlock()
+	switch o.state {
+	case safeDone:
+		return nil
+	case safeFailed:
+		return o.err
+	}
+	if err := o.run(f); err != nil {
+		o.err = err
+		atomic.StoreUint32(&o.state, safeFailed)
+		return err
+	}
+	atomic.StoreUint32(&o.state, safeDone)
+	return nil
+}
+
+func (o *SafeOnce) run(f func()) (err *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	f()
+	return nil
+}
+
+// Err the recorded panic as a *PanicError (nil if there was none)
+func (o *SafeOnce) Err() error {
+	o.m.Lock()
+	defer o.m.Unlock()
+	if o.err == nil {
+		return nil
+	}
+	return o.err
+}
+
+// Failed true if the function panicked
+func (o *SafeOnce) Failed() bool {
+	return atomic.LoadUint32(&o.state) == safeFailed
+}
+
+// Reset forget the function ran (and any panic) so it can run again
+func (o *SafeOnce) Reset() {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.err = nil
+	atomic.StoreUint32(&o.state, safeNotRun)
+}
@@ -0,0 +1,51 @@
+package once
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSafeOnce(t *testing.T) {
+	var o SafeOnce
+	boom := errors.New("boom")
+	calls := 0
+
+	err := o.DoErr(func() { calls++; panic(boom) })
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != boom || !errors.Is(err, boom) {
+		t.Fatalf("expected the panic as an error got %v", err)
+	}
+	if !strings.Contains(string(pe.Stack), "TestSafeOnce") {
+		t.Fatalf("stack does not include the panic site:\n%s", pe.Stack)
+	}
+	if !o.Failed() || o.Err() != err {
+		t.Fatalf("once should be failed with the panic")
+	}
+
+	// every later call gets the same panic and f is not run again
+	if err2 := o.DoErr(func() { calls++ }); err2 != err {
+		t.Fatalf("expected the first panic again got %v", err2)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != err {
+				t.Fatalf("expected a re-panic with the first panic got %v", r)
+			}
+		}()
+		o.Do(func() { calls++ })
+	}()
+	if calls != 1 {
+		t.Fatalf("f ran %d times", calls)
+	}
+
+	o.Reset()
+	if o.Failed() || o.Err() != nil {
+		t.Fatalf("reset should clear the panic")
+	}
+	o.Do(func() { calls++ })
+	o.Do(func() { calls++ })
+	if calls != 2 || o.DoErr(func() { panic("not run") }) != nil {
+		t.Fatalf("f should run once after reset, ran %d", calls-1)
+	}
+}
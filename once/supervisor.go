@@ -0,0 +1,272 @@
+package once
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrDuplicate a component with the name is already registered
+var ErrDuplicate = errors.New("once: component already registered")
+
+// ErrCycle the dependencies loop back on themselves
+var ErrCycle = errors.New("once: dependency cycle")
+
+// ErrMissingDependency a dependency was never registered
+var ErrMissingDependency = errors.New("once: missing dependency")
+
+// errSkip not run because of someone else's failure, not worth reporting
+var errSkip = errors.New("once: skipped")
+
+// Component something a Supervisor starts and stops
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// ComponentFuncs a Component out of a pair of functions (either can be nil)
+type ComponentFuncs struct {
+	StartFunc func(ctx context.Context) error
+	StopFunc  func(ctx context.Context) error
+}
+
+// Start run StartFunc
+func (c ComponentFuncs) Start(ctx context.Context) error {
+	if c.StartFunc == nil {
+		return nil
+	}
+	return c.StartFunc(ctx)
+}
+
+// Stop run StopFunc
+func (c ComponentFuncs) Stop(ctx context.Context) error {
+	if c.StopFunc == nil {
+		return nil
+	}
+	return c.StopFunc(ctx)
+}
+
+// ComponentError a component failed to start or stop
+type ComponentError struct {
+	Name string
+	Err  error
+}
+
+// Error the error string
+func (e *ComponentError) Error() string {
+	return "once: component " + e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap the underlying error
+func (e *ComponentError) Unwrap() error {
+	return e.Err
+}
+
+// Supervisor starts and stops a set of components in dependency order
+//
+// Components start once everything they depend on has started (those that do not
+// depend on each other start in parallel) and stop once everything that depends on
+// them has stopped. Each component has its own Lifecycle so a component that is
+// already running is not started again
+type Supervisor struct {
+	mu    sync.Mutex
+	nodes map[string]*supervised
+	order []string
+}
+
+type supervised struct {
+	name string
+	c    Component
+	deps []string
+	life Lifecycle
+}
+
+// NewSupervisor a new, empty, Supervisor
+func NewSupervisor() *Supervisor {
+	return &Supervisor{nodes: make(map[string]*supervised)}
+}
+
+// Register add a component that needs deps started before it (deps can be registered
+// later, but not in a way that makes a cycle)
+func (s *Supervisor) Register(name string, c Component, deps ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nodes[name]; ok {
+		return &ComponentError{Name: name, Err: ErrDuplicate}
+	}
+	n := &supervised{name: name, c: c, deps: append([]string{}, deps...)}
+	s.nodes[name] = n
+	if path := s.cycle(n, []string{name}, map[string]bool{}); path != nil {
+		delete(s.nodes, name)
+		return &ComponentError{Name: name, Err: fmt.Errorf("%w: %s", ErrCycle, strings.Join(path, " -> "))}
+	}
+	s.order = append(s.order, name)
+	return nil
+}
+
+// cycle the path back to the start of path (nil if there is none) following
+// the dependencies of n, seen are the nodes already looked at, s.mu must be held
+func (s *Supervisor) cycle(n *supervised, path []string, seen map[string]bool) []string {
+	for _, d := range n.deps {
+		if d == path[0] {
+			return append(path, d)
+		}
+		dn, ok := s.nodes[d]
+		if !ok || seen[d] {
+			continue
+		}
+		seen[d] = true
+		if p := s.cycle(dn, append(path, d), seen); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// State the state of the named component, false if there is no such component
+func (s *Supervisor) State(name string) (State, bool) {
+	s.mu.Lock()
+	n, ok := s.nodes[name]
+	s.mu.Unlock()
+	if !ok {
+		return Stopped, false
+	}
+	return n.life.State(), true
+}
+
+// snapshot the registered components, with all the dependencies there if strict
+func (s *Supervisor) snapshot(strict bool) ([]*supervised, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodes := make([]*supervised, 0, len(s.order))
+	for _, name := range s.order {
+		n := s.nodes[name]
+		if strict {
+			for _, d := range n.deps {
+				if _, ok := s.nodes[d]; !ok {
+					return nil, &ComponentError{Name: name, Err: fmt.Errorf("%w: %s", ErrMissingDependency, d)}
+				}
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// Start every component, if any fail to start the ones this call started are stopped
+// again (ones that were already running are left alone) and all the errors (as *ComponentErrors) are returned
+func (s *Supervisor) Start(ctx context.Context) error {
+	nodes, err := s.snapshot(true)
+	if err != nil {
+		return err
+	}
+
+	// only what this call started is rolled back
+	var mu sync.Mutex
+	started := make(map[*supervised]bool)
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := walk(sctx, nodes, false, func(wctx context.Context, n *supervised) error {
+		err := n.life.Start(wctx, func(ctx context.Context) error {
+			if err := n.c.Start(ctx); err != nil {
+				return err
+			}
+			mu.Lock()
+			started[n] = true
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			if sctx.Err() != nil && ctx.Err() == nil && errors.Is(err, context.Canceled) {
+				// we gave up on it
+				return errSkip
+			}
+			cancel()
+		}
+		return err
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// roll back, even if ctx is what failed
+	errs = append(errs, walk(context.WithoutCancel(ctx), nodes, true, func(wctx context.Context, n *supervised) error {
+		mu.Lock()
+		ok := started[n]
+		mu.Unlock()
+		if !ok {
+			return nil
+		}
+		return n.life.Stop(wctx, n.c.Stop)
+	})...)
+	return errors.Join(errs...)
+}
+
+// Stop every component, dependents first, a component that something depending on it
+// failed to stop is left alone, as are ones that never started (or failed to), one that
+// failed to stop before is tried again, all the errors (as *ComponentErrors) are returned
+func (s *Supervisor) Stop(ctx context.Context) error {
+	nodes, _ := s.snapshot(false)
+	return errors.Join(walk(ctx, nodes, true, func(wctx context.Context, n *supervised) error {
+		return n.life.Stop(wctx, n.c.Stop)
+	})...)
+}
+
+// walk run fn on every node once the nodes it waits on (its dependencies, or the
+// nodes that depend on it when reverse) are done, all in parallel as far as that
+// allows, a node is skipped if any of the ones it waits on failed (or were skipped)
+func walk(ctx context.Context, nodes []*supervised, reverse bool, fn func(context.Context, *supervised) error) []error {
+	type walkState struct {
+		done chan struct{}
+		ok   bool
+		wait []*walkState
+	}
+	states := make(map[string]*walkState, len(nodes))
+	for _, n := range nodes {
+		states[n.name] = &walkState{done: make(chan struct{})}
+	}
+	for _, n := range nodes {
+		for _, d := range n.deps {
+			ds, ok := states[d]
+			if !ok {
+				continue
+			}
+			if reverse {
+				ds.wait = append(ds.wait, states[n.name])
+			} else {
+				states[n.name].wait = append(states[n.name].wait, ds)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *supervised, st *walkState) {
+			defer wg.Done()
+			defer close(st.done)
+			for _, w := range st.wait {
+				<-w.done
+				if !w.ok {
+					return
+				}
+			}
+			if err := fn(ctx, n); err != nil {
+				if err != errSkip {
+					mu.Lock()
+					errs = append(errs, &ComponentError{Name: n.name, Err: err})
+					mu.Unlock()
+				}
+				return
+			}
+			st.ok = true
+		}(n, states[n.name])
+	}
+	wg.Wait()
+	return errs
+}
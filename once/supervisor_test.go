@@ -0,0 +1,245 @@
+package once
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder a log of the component starts and stops
+type recorder struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (r *recorder) add(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = append(r.log, s)
+}
+
+func (r *recorder) index(s string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, l := range r.log {
+		if l == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *recorder) component(name string, startErr error) Component {
+	return ComponentFuncs{
+		StartFunc: func(context.Context) error {
+			if startErr != nil {
+				return startErr
+			}
+			r.add("start " + name)
+			return nil
+		},
+		StopFunc: func(context.Context) error {
+			r.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestSupervisorOrder(t *testing.T) {
+	var rec recorder
+	s := NewSupervisor()
+	// db <- cache <- api, db <- queue <- api, log on its own
+	for _, c := range []struct {
+		name string
+		deps []string
+	}{
+		{"api", []string{"cache", "queue"}},
+		{"cache", []string{"db"}},
+		{"queue", []string{"db"}},
+		{"db", nil},
+		{"log", nil},
+	} {
+		if err := s.Register(c.name, rec.component(c.name, nil), c.deps...); err != nil {
+			t.Fatalf("register %s failed: %v", c.name, err)
+		}
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	before := func(a, b string) {
+		t.Helper()
+		if ia, ib := rec.index(a), rec.index(b); ia < 0 || ib < 0 || ia > ib {
+			t.Fatalf("%q should come before %q in %v", a, b, rec.log)
+		}
+	}
+	before("start db", "start cache")
+	before("start db", "start queue")
+	before("start cache", "start api")
+	before("start queue", "start api")
+	if st, ok := s.State("api"); !ok || st != Running {
+		t.Fatalf("api should be running got %v", st)
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	before("stop api", "stop cache")
+	before("stop api", "stop queue")
+	before("stop cache", "stop db")
+	before("stop queue", "stop db")
+	if rec.index("stop log") < 0 {
+		t.Fatalf("log was not stopped: %v", rec.log)
+	}
+}
+
+func TestSupervisorParallel(t *testing.T) {
+	// a and b only start if they run at the same time
+	var wg sync.WaitGroup
+	wg.Add(2)
+	both := func(context.Context) error {
+		wg.Done()
+		wg.Wait()
+		return nil
+	}
+	s := NewSupervisor()
+	s.Register("a", ComponentFuncs{StartFunc: both})
+	s.Register("b", ComponentFuncs{StartFunc: both})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+}
+
+func TestSupervisorRegister(t *testing.T) {
+	s := NewSupervisor()
+	if err := s.Register("a", ComponentFuncs{}, "b"); err != nil {
+		t.Fatalf("forward dependency failed: %v", err)
+	}
+	if err := s.Register("a", ComponentFuncs{}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate got %v", err)
+	}
+	if err := s.Start(context.Background()); !errors.Is(err, ErrMissingDependency) {
+		t.Fatalf("expected ErrMissingDependency got %v", err)
+	}
+	if err := s.Register("b", ComponentFuncs{}, "c"); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	err := s.Register("c", ComponentFuncs{}, "a")
+	if !errors.Is(err, ErrCycle) || err.Error() != "once: component c: once: dependency cycle: c -> a -> b -> c" {
+		t.Fatalf("expected the cycle got %v", err)
+	}
+	if err := s.Register("d", ComponentFuncs{}, "d"); !errors.Is(err, ErrCycle) {
+		t.Fatalf("expected ErrCycle got %v", err)
+	}
+	if _, ok := s.State("c"); ok {
+		t.Fatalf("a cycle should not be registered")
+	}
+}
+
+func TestSupervisorRollback(t *testing.T) {
+	var rec recorder
+	boom := errors.New("boom")
+	s := NewSupervisor()
+	s.Register("db", rec.component("db", nil))
+	s.Register("cache", rec.component("cache", nil), "db")
+	s.Register("api", rec.component("api", boom), "cache")
+	s.Register("web", rec.component("web", nil), "api")
+
+	err := s.Start(context.Background())
+	var ce *ComponentError
+	if !errors.Is(err, boom) || !errors.As(err, &ce) || ce.Name != "api" {
+		t.Fatalf("expected the api error got %v", err)
+	}
+	want := []string{"start db", "start cache", "stop cache", "stop db"}
+	if len(rec.log) != len(want) {
+		t.Fatalf("got %v wanted %v", rec.log, want)
+	}
+	for i := range want {
+		if rec.log[i] != want[i] {
+			t.Fatalf("got %v wanted %v", rec.log, want)
+		}
+	}
+	for name, st := range map[string]State{"db": Stopped, "cache": Stopped, "api": Failed, "web": Stopped} {
+		if got, _ := s.State(name); got != st {
+			t.Fatalf("%s: got %v wanted %v", name, got, st)
+		}
+	}
+}
+
+func TestSupervisorRollbackOnlyStarted(t *testing.T) {
+	var rec recorder
+	boom := errors.New("boom")
+	s := NewSupervisor()
+	s.Register("db", rec.component("db", nil))
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// db was already running, the failed start should not stop it
+	s.Register("cache", rec.component("cache", nil), "db")
+	s.Register("api", rec.component("api", boom), "cache")
+	s.Register("web", rec.component("web", nil), "api")
+	if err := s.Start(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("expected the api error got %v", err)
+	}
+	if rec.index("stop db") != -1 || rec.index("stop cache") == -1 {
+		t.Fatalf("rolled back the wrong things %v", rec.log)
+	}
+	if st, _ := s.State("db"); st != Running {
+		t.Fatalf("db should still be running got %v", st)
+	}
+
+	// api failed and web never started, neither gets stopped
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if rec.index("stop api") != -1 || rec.index("stop web") != -1 || rec.index("stop db") == -1 {
+		t.Fatalf("stopped the wrong things %v", rec.log)
+	}
+	if st, _ := s.State("api"); st != Failed {
+		t.Fatalf("api should still be failed got %v", st)
+	}
+}
+
+func TestSupervisorStopRetry(t *testing.T) {
+	boom := errors.New("boom")
+	starts, stops := 0, 0
+	s := NewSupervisor()
+	s.Register("db", ComponentFuncs{
+		StartFunc: func(context.Context) error { starts++; return nil },
+		StopFunc: func(context.Context) error {
+			stops++
+			if stops == 1 {
+				return boom
+			}
+			return nil
+		},
+	})
+	ctx := context.Background()
+	s.Start(ctx)
+
+	if err := s.Stop(ctx); !errors.Is(err, boom) {
+		t.Fatalf("expected the stop error got %v", err)
+	}
+	if st, _ := s.State("db"); st != Running {
+		t.Fatalf("a failed stop should still be running got %v", st)
+	}
+	// still running, not started again
+	if err := s.Start(ctx); err != nil || starts != 1 {
+		t.Fatalf("started a running component: %v, %d starts", err, starts)
+	}
+	if err := s.Stop(ctx); err != nil || stops != 2 {
+		t.Fatalf("stop should be retried: %v, %d stops", err, stops)
+	}
+	if st, _ := s.State("db"); st != Stopped {
+		t.Fatalf("expected stopped got %v", st)
+	}
+	if err := s.Start(ctx); err != nil || starts != 2 {
+		t.Fatalf("start after stop: %v, %d starts", err, starts)
+	}
+}
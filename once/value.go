@@ -0,0 +1,116 @@
+package once
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoBuilder the OnceValues was not made with NewOnceValues (or NewOnceValuesTTL)
+var ErrNoBuilder = errors.New("once: no builder function")
+
+// lazy the cached result of a builder, optionally only good for a ttl
+type lazy[T any] struct {
+	f   func() (T, error)
+	ttl time.Duration
+
+	m sync.Mutex
+	p atomic.Pointer[lazyResult[T]]
+}
+
+type lazyResult[T any] struct {
+	v       T
+	err     error
+	expires time.Time
+}
+
+func (l *lazy[T]) get() (T, error) {
+	if l.f == nil {
+		var zero T
+		return zero, ErrNoBuilder
+	}
+	if r := l.p.Load(); r != nil && l.fresh(r) {
+		return r.v, r.err
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+	if r := l.p.Load(); r != nil && l.fresh(r) {
+		return r.v, r.err
+	}
+	v, err := l.f()
+	r := &lazyResult[T]{v: v, err: err}
+	if l.ttl > 0 {
+		r.expires = time.Now().Add(l.ttl)
+	}
+	l.p.Store(r)
+	return v, err
+}
+
+func (l *lazy[T]) fresh(r *lazyResult[T]) bool {
+	return l.ttl <= 0 || time.Now().Before(r.expires)
+}
+
+func (l *lazy[T]) reset() {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.p.Store(nil)
+}
+
+// OnceValue lazily builds a value, the builder runs on the first Get and again after a
+// Reset (or once the value is older then the ttl if there is one), a panic in the
+// builder is passed on and the next Get tries again. Make one with NewOnceValue (or
+// NewOnceValueTTL), the zero value has no builder and Get is always the zero T
+type OnceValue[T any] struct {
+	l lazy[T]
+}
+
+// NewOnceValue a OnceValue built by f
+func NewOnceValue[T any](f func() T) *OnceValue[T] {
+	return NewOnceValueTTL(f, 0)
+}
+
+// NewOnceValueTTL a OnceValue built by f that is rebuilt when older then ttl (0 is never)
+func NewOnceValueTTL[T any](f func() T, ttl time.Duration) *OnceValue[T] {
+	return &OnceValue[T]{l: lazy[T]{f: func() (T, error) { return f(), nil }, ttl: ttl}}
+}
+
+// Get the value, building it if needed
+func (o *OnceValue[T]) Get() T {
+	v, _ := o.l.get()
+	return v
+}
+
+// Reset drop the value, the next Get builds a new one
+func (o *OnceValue[T]) Reset() {
+	o.l.reset()
+}
+
+// OnceValues like OnceValue for builders that can fail, the error is cached along with
+// the value (just like sync.OnceValues) until a Reset or the ttl is up. Make one with
+// NewOnceValues (or NewOnceValuesTTL), the zero value has no builder and Get is always
+// the zero T and ErrNoBuilder
+type OnceValues[T any] struct {
+	l lazy[T]
+}
+
+// NewOnceValues a OnceValues built by f
+func NewOnceValues[T any](f func() (T, error)) *OnceValues[T] {
+	return NewOnceValuesTTL(f, 0)
+}
+
+// NewOnceValuesTTL a OnceValues built by f that is rebuilt when older then ttl (0 is never)
+func NewOnceValuesTTL[T any](f func() (T, error), ttl time.Duration) *OnceValues[T] {
+	return &OnceValues[T]{l: lazy[T]{f: f, ttl: ttl}}
+}
+
+// Get the value and error, building them if needed
+func (o *OnceValues[T]) Get() (T, error) {
+	return o.l.get()
+}
+
+// Reset drop the value and error, the next Get builds new ones
+func (o *OnceValues[T]) Reset() {
+	o.l.reset()
+}
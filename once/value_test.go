@@ -0,0 +1,100 @@
+package once
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOnceValue(t *testing.T) {
+	var calls int32
+	o := NewOnceValue(func() int { return int(atomic.AddInt32(&calls, 1)) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v := o.Get(); v != 1 {
+				t.Errorf("got %d wanted 1", v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	o.Reset()
+	if v := o.Get(); v != 2 || o.Get() != 2 {
+		t.Fatalf("got %d wanted a rebuilt 2", v)
+	}
+}
+
+func TestOnceValueTTL(t *testing.T) {
+	calls := 0
+	o := NewOnceValueTTL(func() int { calls++; return calls }, 20*time.Millisecond)
+	if o.Get() != 1 || o.Get() != 1 {
+		t.Fatalf("value should be cached")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v := o.Get(); v != 2 {
+		t.Fatalf("got %d wanted the expired value rebuilt", v)
+	}
+}
+
+func TestOnceValuePanic(t *testing.T) {
+	fail := true
+	o := NewOnceValue(func() string {
+		if fail {
+			panic("boom")
+		}
+		return "ok"
+	})
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the builder panic got %v", r)
+			}
+		}()
+		o.Get()
+	}()
+	fail = false
+	if v := o.Get(); v != "ok" {
+		t.Fatalf("got %q wanted the next Get to rebuild", v)
+	}
+}
+
+func TestOnceValues(t *testing.T) {
+	boom := errors.New("boom")
+	calls := 0
+	o := NewOnceValues(func() (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, boom
+		}
+		return calls, nil
+	})
+	if _, err := o.Get(); err != boom {
+		t.Fatalf("expected the build error got %v", err)
+	}
+	if _, err := o.Get(); err != boom || calls != 1 {
+		t.Fatalf("the error should be cached")
+	}
+	o.Reset()
+	if v, err := o.Get(); err != nil || v != 2 {
+		t.Fatalf("got %d %v wanted a rebuilt 2", v, err)
+	}
+}
+
+func TestOnceValueZero(t *testing.T) {
+	var o OnceValue[string]
+	if v := o.Get(); v != "" {
+		t.Fatalf("zero value got %q", v)
+	}
+	o.Reset()
+
+	var os OnceValues[int]
+	if v, err := os.Get(); v != 0 || err != ErrNoBuilder {
+		t.Fatalf("zero value got %d %v", v, err)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"hash"
 	"hash/fnv"
 	"sync"
+
+	"github.com/wyndhblb/go-utils/bitstream"
 )
 
 // set up a byte[] sync pool for better GC allocation of byte arraies
@@ -130,3 +132,28 @@ func GetFnv64a() hash.Hash64 {
 func PutFnv64a(spl hash.Hash64) {
 	fn64avPool.Put(spl)
 }
+
+var bitStreamPool sync.Pool
+
+// GetBitStream get an empty (MSB first, non strict) bitstream.BitStream from the pool
+// with room for at least size bytes
+func GetBitStream(size int) *bitstream.BitStream {
+	x := bitStreamPool.Get()
+	if x == nil {
+		return bitstream.NewBWriter(size)
+	}
+	b := x.(*bitstream.BitStream)
+	if cap(b.Bytes()) < size {
+		return bitstream.NewBWriter(size)
+	}
+	b.Reset()
+	b.SetOrder(bitstream.MSBFirst)
+	b.SetStrict(false)
+	return b
+}
+
+// PutBitStream put the bitstream.BitStream back in the pool, nothing from its Bytes
+// should be used after this
+func PutBitStream(b *bitstream.BitStream) {
+	bitStreamPool.Put(b)
+}
@@ -0,0 +1,38 @@
+package pools
+
+import (
+	"io"
+	"testing"
+
+	"github.com/wyndhblb/go-utils/bitstream"
+)
+
+func TestBitStreamPool(t *testing.T) {
+	// the pool is free to drop things (it does so at random under -race)
+	for i := 0; i < 100; i++ {
+		b := GetBitStream(16)
+		b.SetOrder(bitstream.LSBFirst)
+		b.SetStrict(true)
+		b.WriteBits(0x1ff, 9)
+		b.ReadBits(4)
+		PutBitStream(b)
+
+		g := GetBitStream(8)
+		if g.Order() != bitstream.MSBFirst || g.BitLen() != 0 || len(g.Bytes()) != 0 || g.Position() != 0 {
+			t.Fatalf("not reset: order %v, %d bits, %d bytes, at %d", g.Order(), g.BitLen(), len(g.Bytes()), g.Position())
+		}
+		// non strict, a clean end is a plain io.EOF
+		if _, err := g.ReadBit(); err != io.EOF {
+			t.Fatalf("expected io.EOF got %v", err)
+		}
+		g.WriteBits(0x5, 3)
+		if u, err := g.ReadBits(3); err != nil || u != 0x5 {
+			t.Fatalf("got %x %v wanted 5", u, err)
+		}
+		PutBitStream(g)
+		if g == b {
+			return
+		}
+	}
+	t.Fatalf("never got a stream back from the pool")
+}